@@ -0,0 +1,160 @@
+package state52
+
+// Report is the result of a static analysis of a state machine's definition.
+// Guards are assumed to be able to return either true or false.
+type Report struct {
+	// Unreachable lists the states that no sequence of events
+	// can reach from the initialState (or the initial state of any region).
+	Unreachable []string
+
+	// Terminal lists the states that have no transitions out of them.
+	Terminal []string
+
+	// DeadEvents lists the events that can never fire, as none
+	// of their transitions' From states are reachable.
+	DeadEvents []string
+
+	// Shadowed lists the transitions that can never be selected from a
+	// From state, as an earlier transition in the same event is unguarded
+	// from that state (or from a composite state containing it).
+	Shadowed []ShadowedTransition
+}
+
+// ShadowedTransition describes a transition that can never be
+// selected from the From state. Transitions are identified by
+// their index within the event's Transitions.
+type ShadowedTransition struct {
+	EventName  string
+	Index      int
+	From       string
+	ShadowedBy int
+}
+
+// Analyze reports unreachable & terminal states, events that
+// can never fire & transitions shadowed by earlier transitions.
+func (sm *State52) Analyze() Report {
+	report := Report{
+		Unreachable: []string{},
+		Terminal:    []string{},
+		DeadEvents:  []string{},
+		Shadowed:    []ShadowedTransition{},
+	}
+
+	reachable := map[string]struct{}{}
+	for _, region := range sm.regions {
+		for state := range sm.reachableFrom(region.initial) {
+			reachable[state] = struct{}{}
+		}
+	}
+	next := sm.nextStates()
+
+	for _, state := range sortedKeys(sm.states) {
+		if _, ok := reachable[state]; !ok {
+			report.Unreachable = append(report.Unreachable, state)
+		}
+		if _, ok := sm.substates[state]; ok {
+			continue
+		}
+		if _, ok := next[state]; !ok {
+			report.Terminal = append(report.Terminal, state)
+		}
+	}
+
+	for _, name := range sortedKeys(sm.events) {
+		event := sm.events[name]
+
+		canFire := false
+		// unguarded holds each From state of the earlier transitions in
+		// the event that are unguarded, in the order they were defined.
+		unguarded := []ShadowedTransition{}
+
+		for i, transition := range event.Transitions {
+			// Event level guards are shared by every transition in the
+			// event, so only the transition's own guards can shadow.
+			isUnguarded := len(transition.Guards) == len(event.Guards)
+
+			for _, from := range transition.From {
+				if _, ok := reachable[from]; ok {
+					canFire = true
+				}
+
+				// Timed transitions are not selected by Event, so neither
+				// shadow nor are shadowed by the other transitions.
+				if transition.After > 0 {
+					continue
+				}
+
+				// A transition from a composite state is also a transition
+				// from each of its substates, so shadows later ones from them.
+				if shadowedBy, ok := sm.shadowedBy(unguarded, from); ok {
+					report.Shadowed = append(report.Shadowed, ShadowedTransition{name, i, from, shadowedBy})
+				} else if isUnguarded {
+					unguarded = append(unguarded, ShadowedTransition{From: from, Index: i})
+				}
+			}
+		}
+
+		if !canFire {
+			report.DeadEvents = append(report.DeadEvents, name)
+		}
+	}
+
+	return report
+}
+
+// shadowedBy returns the index of the first of the unguarded transitions
+// that is selected whenever the state machine is in from.
+func (sm *State52) shadowedBy(unguarded []ShadowedTransition, from string) (int, bool) {
+	for _, earlier := range unguarded {
+		if sm.isIn(from, earlier.From) {
+			return earlier.Index, true
+		}
+	}
+	return 0, false
+}
+
+// nextStates maps each state to the states its transitions go to. The state
+// machine is only ever in states that are not composite, so a transition from
+// a composite state is a transition from each of its (nested) substates.
+func (sm *State52) nextStates() map[string][]string {
+	next := map[string][]string{}
+	for _, event := range sm.events {
+		for _, transition := range event.Transitions {
+			for _, from := range transition.From {
+				for _, leaf := range sm.leaves(from) {
+					next[leaf] = append(next[leaf], sm.resolveTarget(transition.To))
+				}
+			}
+		}
+	}
+	return next
+}
+
+// reachableFrom returns the states that can be reached from initial,
+// including the composite states containing them.
+func (sm *State52) reachableFrom(initial string) map[string]struct{} {
+	next := sm.nextStates()
+
+	initial = sm.resolveTarget(initial)
+	reachable := map[string]struct{}{initial: {}}
+	queue := []string{initial}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, to := range next[state] {
+			if _, ok := reachable[to]; !ok {
+				reachable[to] = struct{}{}
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	// A composite state is reachable if any of its substates are.
+	for _, state := range sortedKeys(reachable) {
+		for _, ancestor := range sm.ancestors(state) {
+			reachable[ancestor] = struct{}{}
+		}
+	}
+
+	return reachable
+}
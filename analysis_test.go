@@ -0,0 +1,65 @@
+package state52_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+func TestAnalyze(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+						{From: []string{"start", "retrying"}, To: "failed_first", Guards: state52.Guards{fnThatReturnsFalse}},
+					},
+				},
+				{
+					Name:   "second_event",
+					Guards: state52.Guards{fnThatReturnsTrue},
+					Transitions: state52.Transitions{
+						{From: []string{"succeeded_first"}, To: "succeeded_second", Guards: state52.Guards{fnThatReturnsTrue}},
+						{From: []string{"succeeded_first"}, To: "failed_second"},
+						{From: []string{"succeeded_first"}, To: "completed"},
+					},
+				},
+				{
+					Name: "retry",
+					Transitions: state52.Transitions{
+						{From: []string{"orphaned"}, To: "retrying"},
+					},
+				},
+			},
+		),
+	)
+
+	report := sm.Analyze()
+
+	expectedUnreachable := []string{"orphaned", "retrying"}
+	if fmt.Sprint(report.Unreachable) != fmt.Sprint(expectedUnreachable) {
+		t.Errorf("expected unreachable states to be %v, got %v", expectedUnreachable, report.Unreachable)
+	}
+
+	expectedTerminal := []string{"completed", "failed_first", "failed_second", "succeeded_second"}
+	if fmt.Sprint(report.Terminal) != fmt.Sprint(expectedTerminal) {
+		t.Errorf("expected terminal states to be %v, got %v", expectedTerminal, report.Terminal)
+	}
+
+	expectedDeadEvents := []string{"retry"}
+	if fmt.Sprint(report.DeadEvents) != fmt.Sprint(expectedDeadEvents) {
+		t.Errorf("expected dead events to be %v, got %v", expectedDeadEvents, report.DeadEvents)
+	}
+
+	expectedShadowed := []state52.ShadowedTransition{
+		{EventName: "first_event", Index: 1, From: "start", ShadowedBy: 0},
+		{EventName: "second_event", Index: 2, From: "succeeded_first", ShadowedBy: 1},
+	}
+	if fmt.Sprint(report.Shadowed) != fmt.Sprint(expectedShadowed) {
+		t.Errorf("expected shadowed transitions to be %v, got %v", expectedShadowed, report.Shadowed)
+	}
+}
@@ -0,0 +1,35 @@
+package state52
+
+import "time"
+
+// Clock tells the time & starts the timers of timed transitions.
+// Pass a state52test.FakeClock to SetClock to control time in tests.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer started by a Clock.
+// Stop reports whether it stopped the timer before it fired.
+type Timer interface {
+	Stop() bool
+}
+
+// realClock is the Clock used unless SetClock is passed.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// SetClock sets the Clock used by timed transitions & the history.
+func SetClock(clock Clock) SetupFunc {
+	return func(sm *State52) error {
+		sm.clock = clock
+		return nil
+	}
+}
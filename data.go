@@ -0,0 +1,110 @@
+package state52
+
+import "context"
+
+// Data accesses the data of type D carried by a state machine, e.g. an order
+// that guards read & callbacks update. It has no state, so declare one per
+// type of data & use its methods:
+//
+//	var orderData state52.Data[Order]
+//	sm := state52.MustNew(orderData.Set(Order{Total: 100}), state52.SetEvents(events))
+//	orderData.Of(sm).Total // 100
+type Data[D any] struct{}
+
+// Carrier is a state machine, or the event being fired on one, whose
+// data can be accessed: a *State52, *Machine[S, E], *Event or *Firing[E].
+type Carrier interface {
+	carrier() *State52
+}
+
+func (sm *State52) carrier() *State52 {
+	return sm
+}
+
+func (e *Event) carrier() *State52 {
+	return &State52{machine: e.machine}
+}
+
+func (m *Machine[S, E]) carrier() *State52 {
+	return m.sm
+}
+
+// Set sets the data carried by the state machine. It is included in each
+// TypedSnapshot. Use it with a Machine via Typed.With.
+func (Data[D]) Set(data D) SetupFunc {
+	return func(sm *State52) error {
+		sm.data = data
+		return nil
+	}
+}
+
+// Of returns the data carried by the state machine,
+// or D's zero value if no data has been set.
+func (Data[D]) Of(c Carrier) D {
+	return dataOf[D](c.carrier().machine)
+}
+
+// Update replaces the data carried by the state machine. The persistFn
+// receives the data as it is when the new state is persisted. Updates made
+// by callbacks are undone if the event fails before the new state is set,
+// e.g. if a before callback or the persistFn returns an error, but not once
+// it has been set. Changes made through a D that is a pointer, map or slice
+// cannot be undone, so replace the data rather than modifying it.
+func (Data[D]) Update(c Carrier, data D) {
+	sm := c.carrier()
+	sm.stateMutex.Lock()
+	defer sm.stateMutex.Unlock()
+	sm.data = data
+}
+
+// dataOf returns the data carried by the state machine,
+// or D's zero value if no data has been set.
+func dataOf[D any](m *machine) D {
+	m.stateMutex.RLock()
+	defer m.stateMutex.RUnlock()
+	data, _ := m.data.(D)
+	return data
+}
+
+// TypedSnapshot is a Snapshot including the state machine's data as a D.
+// It can be encoded, e.g. as JSON, & decoded with the data as a D.
+type TypedSnapshot[D any] struct {
+	Snapshot `yaml:",inline"`
+
+	// Data is the state machine's data.
+	Data D `json:"data" yaml:"data"`
+}
+
+// Snapshot returns a TypedSnapshot of the state machine.
+func (Data[D]) Snapshot(c Carrier) TypedSnapshot[D] {
+	snapshot := c.carrier().Snapshot()
+	data, _ := snapshot.data.(D)
+	return TypedSnapshot[D]{snapshot, data}
+}
+
+// PersistSnapshotFn sets a persistFn that receives the TypedSnapshot the
+// state machine will be in once the transition has been performed,
+// as SetPersistSnapshotFn.
+func (Data[D]) PersistSnapshotFn(fn func(context.Context, TypedSnapshot[D]) error) SetupFunc {
+	return SetPersistSnapshotFn(func(ctx context.Context, snapshot Snapshot) error {
+		data, _ := snapshot.data.(D)
+		return fn(ctx, TypedSnapshot[D]{snapshot, data})
+	})
+}
+
+// Restore restores the state machine, including its data, to a previously
+// persisted TypedSnapshot, as SetSnapshot.
+func (Data[D]) Restore(snapshot TypedSnapshot[D]) SetupFunc {
+	snapshot.Snapshot.data = snapshot.Data
+	return SetSnapshot(snapshot.Snapshot)
+}
+
+// LoadSnapshotFn sets a loadFn that returns a previously persisted
+// TypedSnapshot, restored as with Restore, as SetLoadSnapshotFn.
+func (Data[D]) LoadSnapshotFn(fn func() (TypedSnapshot[D], error)) SetupFunc {
+	return SetLoadSnapshotFn(func() (Snapshot, error) {
+		snapshot, err := fn()
+		snapshot.Snapshot.data = snapshot.Data
+		return snapshot.Snapshot, err
+	})
+}
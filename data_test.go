@@ -0,0 +1,215 @@
+package state52_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+type orderTotals struct {
+	Total int
+	Paid  int
+}
+
+const (
+	completed orderState = "completed"
+
+	complete orderEvent = "complete"
+)
+
+var orderData state52.Data[orderTotals]
+
+var fullyPaid = order.Guard(func(e *orderFiring) bool {
+	return orderData.Of(e).Paid >= orderData.Of(e).Total
+})
+
+var dataEvents = state52.TypedEvents[orderState, orderEvent]{
+	{
+		Name: pay,
+		Transitions: state52.TypedTransitions[orderState, orderEvent]{
+			{From: []orderState{pending}, To: pending},
+		},
+		Callbacks: state52.TypedCallbacks[orderState, orderEvent]{
+			"before": func(m *orderMachine, e *orderFiring) error {
+				totals := orderData.Of(m)
+				totals.Paid += e.Args()[0].(int)
+				orderData.Update(m, totals)
+				return nil
+			},
+		},
+	},
+	{
+		Name: complete,
+		Transitions: state52.TypedTransitions[orderState, orderEvent]{
+			{From: []orderState{pending}, To: completed, Guards: state52.Guards{fullyPaid}},
+		},
+	},
+}
+
+func TestData(t *testing.T) {
+	snapshots := []state52.TypedSnapshot[orderTotals]{}
+	m := state52.MustNewMachine(
+		order.Initial(pending),
+		order.With(orderData.Set(orderTotals{Total: 100})),
+		order.Events(dataEvents),
+		order.With(orderData.PersistSnapshotFn(func(ctx context.Context, snapshot state52.TypedSnapshot[orderTotals]) error {
+			snapshots = append(snapshots, snapshot)
+			return nil
+		})),
+	)
+
+	m.Event(pay, 60)
+	if m.Can(complete) {
+		t.Errorf("expected complete to be guarded until the order is fully paid")
+	}
+
+	m.Event(pay, 40)
+	err := m.Event(complete)
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	expected := orderTotals{Total: 100, Paid: 100}
+	if orderData.Of(m) != expected {
+		t.Errorf("expected data to be %v, got %v", expected, orderData.Of(m))
+	}
+
+	if len(snapshots) != 3 || snapshots[1].Data != (orderTotals{Total: 100, Paid: 100}) || snapshots[0].Data != (orderTotals{Total: 100, Paid: 60}) {
+		t.Errorf("expected the persisted snapshots to include the data, got %v", snapshots)
+	}
+
+	if orderData.Snapshot(m).State != "completed" || orderData.Snapshot(m).Data != expected {
+		t.Errorf("expected the snapshot to be completed with %v, got %v", expected, orderData.Snapshot(m))
+	}
+}
+
+func TestRestoreData(t *testing.T) {
+	m := state52.MustNewMachine(
+		order.Initial(pending),
+		order.With(orderData.Set(orderTotals{Total: 100})),
+		order.Events(dataEvents),
+		order.With(orderData.Restore(state52.TypedSnapshot[orderTotals]{
+			Snapshot: state52.Snapshot{State: "pending"},
+			Data:     orderTotals{Total: 100, Paid: 100},
+		})),
+	)
+
+	err := m.Event(complete)
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+}
+
+func TestDataJSONRoundTrip(t *testing.T) {
+	var persisted []byte
+	m := state52.MustNewMachine(
+		order.Initial(pending),
+		order.With(orderData.Set(orderTotals{Total: 100})),
+		order.Events(dataEvents),
+		order.With(orderData.PersistSnapshotFn(func(ctx context.Context, snapshot state52.TypedSnapshot[orderTotals]) error {
+			var err error
+			persisted, err = json.Marshal(snapshot)
+			return err
+		})),
+	)
+	m.Event(pay, 60)
+
+	expectedJSON := `{"state":"pending","data":{"Total":100,"Paid":60}}`
+	if string(persisted) != expectedJSON {
+		t.Errorf("expected the persisted JSON to be %s, got %s", expectedJSON, persisted)
+	}
+
+	restored := state52.MustNewMachine(
+		order.Initial(pending),
+		order.With(orderData.Set(orderTotals{Total: 100})),
+		order.Events(dataEvents),
+		order.With(orderData.LoadSnapshotFn(func() (state52.TypedSnapshot[orderTotals], error) {
+			snapshot := state52.TypedSnapshot[orderTotals]{}
+			err := json.Unmarshal(persisted, &snapshot)
+			return snapshot, err
+		})),
+	)
+
+	expected := orderTotals{Total: 100, Paid: 60}
+	if orderData.Of(restored) != expected {
+		t.Errorf("expected the restored data to be %v, got %v", expected, orderData.Of(restored))
+	}
+
+	restored.Event(pay, 40)
+	if !restored.Can(complete) {
+		t.Errorf("expected complete to be possible once the restored order is fully paid")
+	}
+}
+
+func TestUntypedData(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("pending"),
+		orderData.Set(orderTotals{Total: 100}),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "pay",
+					Transitions: state52.Transitions{
+						{From: []string{"pending"}, To: "paid", Guards: state52.Guards{
+							state52.GuardFunc(func(e *state52.Event) bool {
+								return e.Args()[0].(int) >= orderData.Of(e).Total
+							}),
+						}},
+					},
+					Callbacks: state52.Callbacks{
+						"after": func(sm *state52.State52, e *state52.Event) error {
+							orderData.Update(sm, orderTotals{Total: 100, Paid: e.Args()[0].(int)})
+							return nil
+						},
+					},
+				},
+			},
+		),
+	)
+
+	if sm.Can("pay", 50) {
+		t.Errorf("expected pay with 50 not to be possible")
+	}
+
+	sm.Event("pay", 100)
+	expected := orderTotals{Total: 100, Paid: 100}
+	if orderData.Of(sm) != expected || orderData.Snapshot(sm).Data != expected {
+		t.Errorf("expected data to be %v, got %v", expected, orderData.Of(sm))
+	}
+}
+
+func TestDataRestoredWhenEventFails(t *testing.T) {
+	failPersist := true
+	m := state52.MustNewMachine(
+		order.Initial(pending),
+		order.With(orderData.Set(orderTotals{Total: 100})),
+		order.Events(dataEvents),
+		order.With(orderData.PersistSnapshotFn(func(ctx context.Context, snapshot state52.TypedSnapshot[orderTotals]) error {
+			if failPersist {
+				return errors.New("database unavailable")
+			}
+			return nil
+		})),
+	)
+
+	var persistFailed state52.PersistFailedError
+	err := m.Event(pay, 60)
+	if !errors.As(err, &persistFailed) {
+		t.Errorf("expected a PersistFailedError, got %v", err)
+	}
+
+	expected := orderTotals{Total: 100}
+	if orderData.Of(m) != expected {
+		t.Errorf("expected data to be restored to %v, got %v", expected, orderData.Of(m))
+	}
+
+	failPersist = false
+	m.Event(pay, 60)
+	expected = orderTotals{Total: 100, Paid: 60}
+	if orderData.Of(m) != expected {
+		t.Errorf("expected data to be %v, got %v", expected, orderData.Of(m))
+	}
+}
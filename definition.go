@@ -0,0 +1,181 @@
+package state52
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Definition is a declarative description of a state machine, e.g. decoded
+// from a JSON or YAML file. Guards & callbacks are referred to by the name
+// they were registered with in a Registry.
+type Definition struct {
+	Initial         string            `json:"initial" yaml:"initial"`
+	Events          []EventDefinition `json:"events" yaml:"events"`
+	GlobalCallbacks map[string]string `json:"global_callbacks,omitempty" yaml:"global_callbacks,omitempty"`
+}
+
+// EventDefinition is the declarative form of an Event.
+// Callbacks maps a callback (e.g. "after") to a registered callback name.
+type EventDefinition struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Guards      []string               `json:"guards,omitempty" yaml:"guards,omitempty"`
+	Callbacks   map[string]string      `json:"callbacks,omitempty" yaml:"callbacks,omitempty"`
+	Transitions []TransitionDefinition `json:"transitions" yaml:"transitions"`
+}
+
+// TransitionDefinition is the declarative form of a Transition.
+// Callbacks maps a callback (e.g. "success") to a registered transition callback name.
+type TransitionDefinition struct {
+	From      []string          `json:"from" yaml:"from"`
+	To        string            `json:"to" yaml:"to"`
+	Guards    []string          `json:"guards,omitempty" yaml:"guards,omitempty"`
+	Callbacks map[string]string `json:"callbacks,omitempty" yaml:"callbacks,omitempty"`
+}
+
+// Registry holds the Go functions a Definition's guard & callback names refer to.
+type Registry struct {
+	guards              map[string]Guard
+	callbacks           map[string]callback
+	transitionCallbacks map[string]tCallback
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		guards:              map[string]Guard{},
+		callbacks:           map[string]callback{},
+		transitionCallbacks: map[string]tCallback{},
+	}
+}
+
+// RegisterGuard registers a guard under name. The guard is Named
+// name, so the name is used by error messages & the exporters.
+func (r *Registry) RegisterGuard(name string, guard Guard) *Registry {
+	r.guards[name] = Named(name, "", guard)
+	return r
+}
+
+// RegisterCallback registers a global or event callback under name.
+func (r *Registry) RegisterCallback(name string, fn func(*State52, *Event) error) *Registry {
+	r.callbacks[name] = fn
+	return r
+}
+
+// RegisterTransitionCallback registers a transition callback under name.
+func (r *Registry) RegisterTransitionCallback(name string, fn func(*State52, *Event, *Transition) error) *Registry {
+	r.transitionCallbacks[name] = fn
+	return r
+}
+
+// SetDefinition sets the initialState, events & global callbacks described
+// by def. Every guard & callback name must have been registered in registry.
+func SetDefinition(def Definition, registry *Registry) SetupFunc {
+	return func(sm *State52) error {
+		problems := []string{}
+
+		guards := func(names []string) []Guard {
+			resolved := []Guard{}
+			for _, name := range names {
+				guard, ok := registry.guards[name]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s is not a registered Guard.", name))
+					continue
+				}
+				resolved = append(resolved, guard)
+			}
+			return resolved
+		}
+
+		callbacks := func(names map[string]string) Callbacks {
+			resolved := Callbacks{}
+			for _, key := range sortedKeys(names) {
+				fn, ok := registry.callbacks[names[key]]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s is not a registered Callback.", names[key]))
+					continue
+				}
+				resolved[key] = fn
+			}
+			return resolved
+		}
+
+		transitionCallbacks := func(names map[string]string) TransitionCallbacks {
+			resolved := TransitionCallbacks{}
+			for _, key := range sortedKeys(names) {
+				fn, ok := registry.transitionCallbacks[names[key]]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s is not a registered Transition Callback.", names[key]))
+					continue
+				}
+				resolved[key] = fn
+			}
+			return resolved
+		}
+
+		events := Events{}
+		for _, eventDef := range def.Events {
+			event := Event{
+				Name:      eventDef.Name,
+				Guards:    guards(eventDef.Guards),
+				Callbacks: callbacks(eventDef.Callbacks),
+			}
+
+			for _, transitionDef := range eventDef.Transitions {
+				event.Transitions = append(event.Transitions, Transition{
+					From:      transitionDef.From,
+					To:        transitionDef.To,
+					Guards:    guards(transitionDef.Guards),
+					Callbacks: transitionCallbacks(transitionDef.Callbacks),
+				})
+			}
+
+			events = append(events, event)
+		}
+
+		// The definition is applied even if names failed to resolve, so the
+		// state machine is validated without reporting it as empty.
+		sm.initialState = def.Initial
+		sm.events = mapEvents(events)
+		sm.globalCallbacks = callbacks(def.GlobalCallbacks)
+
+		if len(problems) > 0 {
+			return ValidationError{problems}
+		}
+		return nil
+	}
+}
+
+// ParseJSON decodes a JSON Definition. Unknown fields are rejected.
+func ParseJSON(data []byte) (Definition, error) {
+	def := Definition{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&def); err != nil {
+		return Definition{}, err
+	}
+	return def, nil
+}
+
+// Load builds a state machine from a Definition decoded with unmarshal,
+// e.g. yaml.Unmarshal, resolving guard & callback names against registry.
+// Further options (e.g. SetPersistFn) can be passed.
+func Load(data []byte, unmarshal func([]byte, interface{}) error, registry *Registry, options ...SetupFunc) (*State52, error) {
+	def := Definition{}
+	if err := unmarshal(data, &def); err != nil {
+		return nil, err
+	}
+	return New(append([]SetupFunc{SetDefinition(def, registry)}, options...)...)
+}
+
+// LoadJSON builds a state machine from a JSON Definition,
+// resolving guard & callback names against registry.
+// Further options (e.g. SetPersistFn) can be passed.
+func LoadJSON(data []byte, registry *Registry, options ...SetupFunc) (*State52, error) {
+	def, err := ParseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return New(append([]SetupFunc{SetDefinition(def, registry)}, options...)...)
+}
@@ -0,0 +1,126 @@
+package state52_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+const orderDefinition = `{
+	"initial": "unpaid",
+	"events": [
+		{
+			"name": "pay",
+			"callbacks": {"after": "record"},
+			"transitions": [
+				{"from": ["unpaid"], "to": "paid", "guards": ["sufficient_amount"], "callbacks": {"success": "notify"}}
+			]
+		},
+		{
+			"name": "ship",
+			"transitions": [
+				{"from": ["paid"], "to": "shipped"}
+			]
+		}
+	],
+	"global_callbacks": {"after_all_events": "record"}
+}`
+
+func TestLoadJSON(t *testing.T) {
+	recorded := []string{}
+	amount := 0
+
+	registry := state52.NewRegistry().
+		RegisterGuard("sufficient_amount", state52.Condition(func() bool {
+			return amount >= 100
+		})).
+		RegisterCallback("record", func(sm *state52.State52, e *state52.Event) error {
+			recorded = append(recorded, e.Name)
+			return nil
+		}).
+		RegisterTransitionCallback("notify", func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+			recorded = append(recorded, "notify:"+t.To)
+			return nil
+		})
+
+	persisted := ""
+	sm, err := state52.LoadJSON([]byte(orderDefinition), registry,
+		state52.SetPersistFn(func(newState string) error {
+			persisted = newState
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "unpaid" {
+		t.Errorf("expected state to be 'unpaid', got %s", sm.CurrentState())
+	}
+
+	amount = 50
+	var cannotTransition state52.CannotTransitionError
+	if !errors.As(sm.Event("pay"), &cannotTransition) || cannotTransition.Candidates[0].Guard != "sufficient_amount" {
+		t.Errorf("expected pay with 50 to be rejected by the sufficient_amount guard, got %v", cannotTransition)
+	}
+
+	amount = 150
+	err = sm.Event("pay")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "paid" || persisted != "paid" {
+		t.Errorf("expected state & persisted state to be 'paid', got %s & %s", sm.CurrentState(), persisted)
+	}
+
+	expectedRecorded := []string{"notify:paid", "pay", "pay"}
+	if fmt.Sprint(recorded) != fmt.Sprint(expectedRecorded) {
+		t.Errorf("expected callbacks to record %v, got %v", expectedRecorded, recorded)
+	}
+}
+
+func TestLoadWithUnmarshal(t *testing.T) {
+	sm, err := state52.Load([]byte(orderDefinition), json.Unmarshal, state52.NewRegistry().
+		RegisterGuard("sufficient_amount", fnThatReturnsTrue).
+		RegisterCallback("record", func(sm *state52.State52, e *state52.Event) error { return nil }).
+		RegisterTransitionCallback("notify", func(sm *state52.State52, e *state52.Event, t *state52.Transition) error { return nil }),
+	)
+	if err != nil {
+		t.Fatalf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	sm.Event("pay")
+	if sm.CurrentState() != "paid" {
+		t.Errorf("expected state to be 'paid', got %s", sm.CurrentState())
+	}
+}
+
+func TestLoadJSONUnregisteredNames(t *testing.T) {
+	_, err := state52.LoadJSON([]byte(orderDefinition), state52.NewRegistry())
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{
+		"record is not a registered Callback.",
+		"sufficient_amount is not a registered Guard.",
+		"notify is not a registered Transition Callback.",
+		"record is not a registered Callback.",
+	}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+}
+
+func TestParseJSONUnknownField(t *testing.T) {
+	_, err := state52.ParseJSON([]byte(`{"initial": "start", "evnets": []}`))
+	if err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+}
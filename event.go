@@ -1,24 +1,143 @@
 package state52
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"reflect"
 )
 
 // Event performs the first available transition that is found.
 func (sm *State52) Event(event string, args ...interface{}) error {
+	return sm.EventContext(context.Background(), event, args...)
+}
+
+// EventContext performs the first available transition that is found.
+// args are available to guards & callbacks via Event.Args().
+// ctx is passed to guards, callbacks & the persistFn (via Event.Context()).
+// If ctx is done before the new state has been persisted the event is
+// aborted and an EventCanceledError is returned.
+//
+// Errors from before, transition after & on_exit callbacks abort the event
+// before the state is set. Once the state is set, errors from on_enter,
+// success, after & after_all_events callbacks are joined and returned. Errors from ensure
+// callbacks are joined after the primary error, never in place of it.
+//
+// Events are fired one at a time: concurrent callers wait for the event
+// being fired to complete, or for ctx to be done. Callbacks may fire further
+// events through the State52 they are passed, which does not wait as the
+// event being fired holds the lock. It must only be used by the callback
+// itself: not once it returns, nor from a goroutine it starts. Firing an
+// event on any other handle from within a callback or guard waits forever.
+func (sm *State52) EventContext(ctx context.Context, event string, args ...interface{}) error {
+	if sm.nested {
+		return sm.fire(ctx, event, args, nil)
+	}
+
+	if err := sm.eventLock.lockContext(ctx); err != nil {
+		return EventCanceledError{event, err}
+	}
+	defer sm.eventLock.unlock()
+
+	return sm.drain(ctx, event, args, nil)
+}
+
+// drain fires the event, followed by any events raised by callbacks,
+// in the order they were raised. The eventLock must be held.
+func (sm *State52) drain(ctx context.Context, event string, args []interface{}, timed *timedTransition) error {
+	nested := &State52{machine: sm.machine, nested: true}
+	err := nested.fire(ctx, event, args, timed)
+
+	for len(sm.queue) > 0 {
+		next := sm.queue[0]
+		sm.queue = sm.queue[1:]
+		err = joinErrors(err, nested.fire(ctx, next.name, next.args, nil))
+	}
+
+	return err
+}
+
+// Raise fires an event from within a callback once the event being fired
+// has completed (i.e. after its after_all_events & ensure callbacks), rather
+// than straight away as Event does. Errors from raised events are joined to
+// the error returned by the outermost Event call.
+// Outside of a callback Raise is the same as Event.
+func (sm *State52) Raise(event string, args ...interface{}) error {
+	if !sm.nested {
+		return sm.Event(event, args...)
+	}
+
+	if _, ok := sm.events[event]; !ok {
+		return EventNotRegisteredError{event}
+	}
+
+	sm.queue = append(sm.queue, queuedEvent{event, args})
+	return nil
+}
+
+// fire performs the event, or only the timed transition if one is
+// given. The eventLock must be held.
+func (sm *State52) fire(ctx context.Context, event string, args []interface{}, timed *timedTransition) (err error) {
+	// Record the event once it has completed, including its ensure callbacks.
+	start := sm.clock.Now()
+	fromState := sm.CurrentState()
+	toState := ""
+	defer func() {
+		sm.record(Record{event, fromState, toState, args, start, sm.clock.Now().Sub(start), err})
+	}()
+
 	selectedEvent, ok := sm.events[event]
 	if !ok {
 		return EventNotRegisteredError{event}
 	}
 
+	if sm.IsFinal() {
+		return MachineCompletedError{sm.CurrentState(), event}
+	}
+	selectedEvent.ctx = ctx
+	selectedEvent.args = args
+	selectedEvent.machine = sm.machine
+
+	// Updates made to the data by callbacks are undone if the
+	// event fails before the new state is set, see Data.Update.
+	data := dataOf[interface{}](sm.machine)
+
+	sm.notify(func(o Observer) { o.OnBeforeEvent(sm, &selectedEvent) })
+
 	// defer (i.e. ensure) that any ensure_on_all_events callback will be called.
+	// Ensure callbacks can inspect the outcome of the event via Event.Err().
 	defer func() {
-		sm.ensureEventCallback(&selectedEvent)
-		sm.ensureAllEventsCallback(&selectedEvent)
+		if err != nil && toState == "" {
+			sm.stateMutex.Lock()
+			sm.data = data
+			sm.stateMutex.Unlock()
+		}
+
+		var persistFailed PersistFailedError
+		if err != nil && toState == "" && !errors.As(err, &persistFailed) {
+			sm.notify(func(o Observer) { o.OnRejected(sm, &selectedEvent, err) })
+		}
+
+		selectedEvent.err = err
+		err = joinErrors(
+			err,
+			sm.ensureEventCallback(&selectedEvent),
+			sm.ensureAllEventsCallback(&selectedEvent),
+		)
+
+		sm.notify(func(o Observer) { o.OnEnsure(sm, &selectedEvent, err) })
 	}()
 
-	err := sm.beforeAllEventsCallback(&selectedEvent)
+	err = selectedEvent.canceled()
+	if err != nil {
+		return err
+	}
+
+	err = sm.beforeAllEventsCallback(&selectedEvent)
+	if err != nil {
+		return err
+	}
+
+	err = selectedEvent.canceled()
 	if err != nil {
 		return err
 	}
@@ -28,181 +147,397 @@ func (sm *State52) Event(event string, args ...interface{}) error {
 		return err
 	}
 
-	selectedTransition := Transition{}
-	for _, transition := range selectedEvent.Transitions {
-		// If the 'from' states do not include the CurrentState
+	// Select a transition in each region that can perform the event.
+	currentStates := sm.regionStates()
+	selected, candidates, err := sm.selectTransitions(&selectedEvent, currentStates, timed)
+	if err != nil {
+		return err
+	}
+
+	// If we could not select a transition to execute we
+	// return a CannotTransitionError
+	if len(selected) == 0 {
+		return CannotTransitionError{sm.CurrentState(), event, candidates}
+	}
+
+	err = selectedEvent.canceled()
+	if err != nil {
+		return err
+	}
+
+	// Transition after
+	for _, s := range selected {
+		err = sm.afterTransitionCallback(s.transition, &selectedEvent)
+		if err != nil {
+			return err
+		}
+	}
+
+	// State exit, from the current state up to the state
+	// containing both the current & target states.
+	targetStates := append([]string{}, currentStates...)
+	exited := []string{}
+	sm.stateMutex.RLock()
+	for i, s := range selected {
+		selected[i].to = sm.historyTarget(s.transition.To, s.transition.History, sm.lastActive)
+		selected[i].exit, selected[i].enter = sm.exitAndEnter(s.from, selected[i].to)
+		targetStates[s.region] = selected[i].to
+		exited = append(exited, selected[i].exit...)
+	}
+	sm.stateMutex.RUnlock()
+	lastActive := sm.remember(exited)
+
+	for _, s := range selected {
+		for _, state := range s.exit {
+			err = sm.exitStateCallback(state, &selectedEvent)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = selectedEvent.canceled()
+	if err != nil {
+		return err
+	}
+
+	// Call the persistFn if it has been passed. This happens before the
+	// new state is set, so if persisting fails the state is left unchanged.
+	targetState := sm.configuration(targetStates)
+	if sm.persistFn != nil {
+		err = sm.persistFn(ctx, Snapshot{State: targetState, LastActive: lastActive, data: dataOf[interface{}](sm.machine)})
+		if err != nil {
+			persistFailed := PersistFailedError{Message: err, EventName: event, From: sm.CurrentState(), To: targetState}
+			sm.notify(func(o Observer) { o.OnPersistFailed(sm, &selectedEvent, persistFailed) })
+			return persistFailed
+		}
+	}
+
+	// Perform the transition
+	fromState = sm.CurrentState()
+	sm.setCurrentStates(targetStates, lastActive)
+	sm.checkDone()
+	toState = targetState
+	for _, s := range selected {
+		sm.stopTimers(s.exit)
+		sm.startTimers(s.enter)
+		sm.notify(func(o Observer) { o.OnTransition(sm, &selectedEvent, s.from, s.to) })
+	}
+
+	// The state has changed, so every remaining callback is called
+	// and any errors are returned together.
+	errs := []error{}
+	for _, s := range selected {
+		for _, state := range s.enter {
+			errs = append(errs, sm.enterStateCallback(state, &selectedEvent))
+		}
+		errs = append(errs, sm.successTransitionCallback(s.transition, &selectedEvent))
+	}
+
+	return joinErrors(append(errs,
+		sm.afterEventCallback(&selectedEvent),
+		sm.afterAllEventsCallback(&selectedEvent),
+	)...)
+}
+
+// regionTransition is a transition selected to be performed in a region.
+type regionTransition struct {
+	region     int
+	transition Transition
+
+	// from & to are the region's states before & after the transition.
+	from, to string
+
+	// exit & enter are the states exited & entered by the transition.
+	exit, enter []string
+}
+
+// selectTransitions selects a transition for each region that can perform
+// the event from its current state, & returns the candidate transitions
+// rejected by guards. If a timed transition is given, only it is
+// selected, in the region whose state started its timer.
+func (sm *State52) selectTransitions(e *Event, currentStates []string, timed *timedTransition) ([]regionTransition, []Candidate, error) {
+	selected := []regionTransition{}
+	candidates := []Candidate{}
+	for i, state := range currentStates {
+		if timed != nil && !sm.isIn(state, timed.state) {
+			continue
+		}
+
+		transition, ok, rejected, err := sm.selectTransition(e, state, timed)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			selected = append(selected, regionTransition{region: i, transition: transition, from: state})
+		}
+		candidates = append(candidates, rejected...)
+	}
+	return selected, candidates, nil
+}
+
+// selectTransition returns the first transition of the event that can be
+// performed from currentState, i.e. whose guards all pass, & the candidate
+// transitions before it that were rejected by a guard. Timed transitions
+// are skipped unless timed is the transition.
+func (sm *State52) selectTransition(e *Event, currentState string, timed *timedTransition) (Transition, bool, []Candidate, error) {
+	rejected := []Candidate{}
+
+	for i, transition := range e.Transitions {
+		// Timed transitions are only performed by their timer.
+		if (timed == nil && transition.After > 0) || (timed != nil && timed.index != i) {
+			continue
+		}
+
+		// If the 'from' states do not include the currentState
 		// we continue to next iteration.
-		if !stringInSlice(sm.CurrentState(), transition.From) {
+		if !sm.matchesFrom(currentState, transition.From) {
 			continue
 		}
 
 		// If there is no guard we select this transition
 		if len(transition.Guards) == 0 { // No Guards not defined
-			selectedTransition = transition
-			break
-		} else {
-			guardsResult := false
-
-			for _, guard := range transition.Guards {
-				if guard() == true { // Guard defined
-					guardsResult = true
-				} else {
-					guardsResult = false
-					break
-				}
+			return transition, true, rejected, nil
+		}
+
+		guardsResult := false
+
+		for j, guard := range transition.Guards {
+			err := e.canceled()
+			if err != nil {
+				return Transition{}, false, nil, err
 			}
 
-			if guardsResult == true {
-				selectedTransition = transition
+			ok, err := guard.Check(e)
+			var rejection Rejection
+			if errors.As(err, &rejection) {
+				ok = false
+			} else if err != nil {
+				return Transition{}, false, nil, GuardFailedError{e.Name, guardName(guard, j), err}
+			}
+
+			if ok { // Guard defined
+				guardsResult = true
+			} else {
+				guardsResult = false
+				rejected = append(rejected, Candidate{i, currentState, transition.To, guardName(guard, j), rejection.Reason})
 				break
 			}
 		}
+
+		if guardsResult == true {
+			return transition, true, rejected, nil
+		}
 	}
 
-	// If we could not select a transition to execute we
-	// return a CannotTransitionError
-	if reflect.DeepEqual(selectedTransition, Transition{}) {
-		return CannotTransitionError{sm.CurrentState(), event}
+	return Transition{}, false, rejected, nil
+}
+
+// Context returns the context the event was fired with.
+// It is never nil: events fired via Event() use context.Background().
+func (e *Event) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
 	}
+	return e.ctx
+}
 
-	// Transition after
-	sm.afterTransitionCallback(selectedTransition, &selectedEvent)
+// Args returns the arguments the event was fired with.
+func (e *Event) Args() []interface{} {
+	return e.args
+}
 
-	// Perform the transition
-	sm.setCurrentState(selectedTransition.To)
+// Err returns the error the event failed with, if any.
+// It is set before ensure callbacks are called.
+func (e *Event) Err() error {
+	return e.err
+}
 
-	// Call the persistFn if it has been passed
-	if sm.persistFn != nil {
-		err = sm.persistFn(selectedTransition.To)
+// canceled returns an EventCanceledError if the event's context is done.
+func (e *Event) canceled() error {
+	if err := e.Context().Err(); err != nil {
+		return EventCanceledError{e.Name, err}
+	}
+	return nil
+}
+
+// joinErrors returns nil if all errs are nil, the error itself if only
+// one is non-nil & an errors.Join of them otherwise.
+func joinErrors(errs ...error) error {
+	nonNil := []error{}
+	for _, err := range errs {
 		if err != nil {
-			return PersistFailedError{err, event}
+			nonNil = append(nonNil, err)
 		}
 	}
 
-	// Transition success
-	sm.successTransitionCallback(selectedTransition, &selectedEvent)
-
-	sm.afterEventCallback(&selectedEvent)
-	sm.afterAllEventsCallback(&selectedEvent)
-
-	return selectedEvent.err
+	switch len(nonNil) {
+	case 0:
+		return nil
+	case 1:
+		return nonNil[0]
+	default:
+		return errors.Join(nonNil...)
+	}
 }
 
-func (sm *State52) setCurrentState(state string) {
+func (sm *State52) setCurrentStates(states []string, lastActive map[string]string) {
 	sm.stateMutex.Lock()
-	sm.currentState = state
+	sm.currentStates = states
+	sm.lastActive = lastActive
 	sm.stateMutex.Unlock()
 }
 
 // beforeEventCallback
 func (sm *State52) beforeEventCallback(e *Event) error {
-	if fn, ok := e.Callbacks["before"]; ok {
-		err := fn(sm, e)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return runHooks(e.chains["before"], true, func(h Hook) error { return h.Fn(sm, e) })
 }
 
 // beforeAllEventsCallback
 func (sm *State52) beforeAllEventsCallback(e *Event) error {
-	if fn, ok := sm.globalCallbacks["before_all_events"]; ok {
-		err := fn(sm, e)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return runHooks(sm.globalChains["before_all_events"], true, func(h Hook) error { return h.Fn(sm, e) })
 }
 
 // afterTransitionCallback
 func (sm *State52) afterTransitionCallback(t Transition, e *Event) error {
-	if fn, ok := t.Callbacks["after"]; ok {
-		err := fn(sm, e, &t)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return runHooks(t.chains["after"], true, func(h TransitionHook) error { return h.Fn(sm, e, &t) })
 }
 
 // successTransitionCallback
 func (sm *State52) successTransitionCallback(t Transition, e *Event) error {
-	if fn, ok := t.Callbacks["success"]; ok {
-		err := fn(sm, e, &t)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return runHooks(t.chains["success"], false, func(h TransitionHook) error { return h.Fn(sm, e, &t) })
 }
 
 // afterEventCallback
 func (sm *State52) afterEventCallback(e *Event) error {
-	if fn, ok := e.Callbacks["after"]; ok {
-		err := fn(sm, e)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return runHooks(e.chains["after"], false, func(h Hook) error { return h.Fn(sm, e) })
 }
 
 // ensureEventCallback
 func (sm *State52) ensureEventCallback(e *Event) error {
-	if fn, ok := e.Callbacks["ensure"]; ok {
-		err := fn(sm, e)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return runHooks(e.chains["ensure"], false, func(h Hook) error { return h.Fn(sm, e) })
 }
 
 // afterAllEventsCallback
 func (sm *State52) afterAllEventsCallback(e *Event) error {
-	if fn, ok := sm.globalCallbacks["after_all_events"]; ok {
-		err := fn(sm, e)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return runHooks(sm.globalChains["after_all_events"], false, func(h Hook) error { return h.Fn(sm, e) })
 }
 
 // ensureAllEventsCallback
 func (sm *State52) ensureAllEventsCallback(e *Event) error {
-	if fn, ok := sm.globalCallbacks["ensure_all_events"]; ok {
-		err := fn(sm, e)
-		if err != nil {
-			return err
-		}
+	return runHooks(sm.globalChains["ensure_all_events"], false, func(h Hook) error { return h.Fn(sm, e) })
+}
+
+// exitStateCallback calls the on_exit callbacks for state, then the global ones.
+func (sm *State52) exitStateCallback(state string, e *Event) error {
+	err := runHooks(sm.stateChains[state]["on_exit"], true, func(h StateHook) error { return h.Fn(sm, e, state) })
+	if err != nil {
+		return err
 	}
-	return nil
+	return runHooks(sm.globalStateChains["on_exit"], true, func(h StateHook) error { return h.Fn(sm, e, state) })
+}
+
+// enterStateCallback calls the on_enter callbacks for state, then the global ones.
+func (sm *State52) enterStateCallback(state string, e *Event) error {
+	return joinErrors(
+		runHooks(sm.stateChains[state]["on_enter"], false, func(h StateHook) error { return h.Fn(sm, e, state) }),
+		runHooks(sm.globalStateChains["on_enter"], false, func(h StateHook) error { return h.Fn(sm, e, state) }),
+	)
 }
 
-// PersistFailedError when the persistFn provided returns an error
+// PersistFailedError when the persistFn provided returns an error.
+// The state machine remains in the From state.
 type PersistFailedError struct {
 	Message   error
 	EventName string
+	From      string
+	To        string
 }
 
 func (e PersistFailedError) Error() string {
 	return fmt.Sprintf("Perist failed for %s: %s", e.EventName, e.Message)
 }
 
+// Unwrap returns the error returned by the persistFn.
+func (e PersistFailedError) Unwrap() error {
+	return e.Message
+}
+
+// EventCanceledError will be returned when the context passed
+// to EventContext() is done before the event could complete.
+type EventCanceledError struct {
+	EventName string
+	Err       error
+}
+
+func (e EventCanceledError) Error() string {
+	return fmt.Sprintf("%s was canceled: %s", e.EventName, e.Err)
+}
+
+// Unwrap returns the context error, so errors.Is(err, context.Canceled) works.
+func (e EventCanceledError) Unwrap() error {
+	return e.Err
+}
+
 // CannotTransitionError will be returned when calling Event()
 // with a CurrentState that cannot be transitioned from.
 type CannotTransitionError struct {
 	CurrentState string
 	EventName    string
+
+	// Candidates lists the transitions from the current
+	// state that were evaluated & rejected by a guard.
+	Candidates []Candidate
+}
+
+// Candidate is a transition that was evaluated when calling Event(),
+// & rejected by one of its guards.
+type Candidate struct {
+	// Index is the index of the transition within the event's Transitions.
+	Index int
+
+	// From is the current state it was evaluated from & To is its To.
+	From string
+	To   string
+
+	// Guard is the name of the guard that rejected the transition,
+	// and Reason the reason it gave via Reject (if any).
+	Guard  string
+	Reason string
 }
 
 func (e CannotTransitionError) Error() string {
 	return fmt.Sprintf("Cannot transition from %s when calling %s.", e.CurrentState, e.EventName)
 }
 
+// MachineCompletedError will be returned when calling Event()
+// once the state machine has entered a final state.
+type MachineCompletedError struct {
+	CurrentState string
+	EventName    string
+}
+
+func (e MachineCompletedError) Error() string {
+	return fmt.Sprintf("Cannot call %s as the state machine completed in %s.", e.EventName, e.CurrentState)
+}
+
+// GuardFailedError will be returned when calling Event()
+// if a guard returns an error other than a Rejection.
+type GuardFailedError struct {
+	EventName string
+	Guard     string
+	Err       error
+}
+
+func (e GuardFailedError) Error() string {
+	return fmt.Sprintf("Guard %s failed when calling %s: %s.", e.Guard, e.EventName, e.Err)
+}
+
+// Unwrap returns the error returned by the guard.
+func (e GuardFailedError) Unwrap() error {
+	return e.Err
+}
+
 // EventNotRegisteredError will be returned when calling Event()
 // with an event name that is not registered.
 type EventNotRegisteredError struct {
@@ -0,0 +1,145 @@
+package state52
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// edge is a single From -> To transition of an event, as drawn by the exporters.
+type edge struct {
+	from   string
+	to     string
+	event  string
+	guards []string
+	after  time.Duration
+}
+
+// label returns the text an edge is labelled with.
+func (e edge) label() string {
+	label := e.event
+	if e.after > 0 {
+		label += " [after " + e.after.String() + "]"
+	}
+	if len(e.guards) > 0 {
+		label += " [" + strings.Join(e.guards, " && ") + "]"
+	}
+	return label
+}
+
+// edges returns every From -> To transition, ordered by event name
+// & then by the order the transitions were defined in.
+func (sm *State52) edges() []edge {
+	edges := []edge{}
+	for _, name := range sortedKeys(sm.events) {
+		for _, transition := range sm.events[name].Transitions {
+			for _, from := range transition.From {
+				edges = append(edges, edge{from, transition.To, name, guardNames(transition.Guards), transition.After})
+			}
+		}
+	}
+	return edges
+}
+
+// guardNames returns the unique names of the guards, in order.
+// Guards without a name are all "guarded".
+func guardNames(guards []Guard) []string {
+	names := []string{}
+	for _, guard := range guards {
+		if name := nameOf(guard); !stringInSlice(name, names) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DOT returns a Graphviz DOT description of the state machine. The initial
+// state (of each region) is pointed to by a start node & the current state is filled.
+func (sm *State52) DOT() string {
+	var b strings.Builder
+
+	b.WriteString("digraph state52 {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\t\"__start\" [shape=point];\n")
+
+	currentStates := sm.regionStates()
+	for _, state := range sm.States() {
+		if stringInSlice(state, currentStates) {
+			fmt.Fprintf(&b, "\t%s [style=filled];\n", dotQuote(state))
+		} else {
+			fmt.Fprintf(&b, "\t%s;\n", dotQuote(state))
+		}
+	}
+
+	for _, region := range sm.regions {
+		fmt.Fprintf(&b, "\t\"__start\" -> %s;\n", dotQuote(region.initial))
+	}
+	for _, e := range sm.edges() {
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", dotQuote(e.from), dotQuote(e.to), dotQuote(e.label()))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote returns text as a DOT quoted string. Graphviz does not understand
+// Go's escapes, as %q would write, so only " & \ are escaped, a newline is
+// written as \n & any other control character as a space.
+func dotQuote(text string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range text {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case unicode.IsControl(r):
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// mermaidEscaper escapes text that Mermaid would otherwise parse,
+// using its entity codes.
+var mermaidEscaper = strings.NewReplacer(
+	"#", "#35;",
+	"\"", "#quot;",
+	";", "#59;",
+	":", "#58;",
+	"\n", " ",
+)
+
+// Mermaid returns a Mermaid stateDiagram-v2 description of the state machine.
+// The initial state is pointed to by [*] & the current state has the class "current".
+// States are declared with an id, e.g. state "in progress" as s0, so any
+// state name can be drawn.
+func (sm *State52) Mermaid() string {
+	var b strings.Builder
+
+	b.WriteString("stateDiagram-v2\n")
+	ids := map[string]string{}
+	for i, state := range sm.States() {
+		ids[state] = fmt.Sprintf("s%d", i)
+		fmt.Fprintf(&b, "    state \"%s\" as %s\n", mermaidEscaper.Replace(state), ids[state])
+	}
+
+	for _, region := range sm.regions {
+		fmt.Fprintf(&b, "    [*] --> %s\n", ids[region.initial])
+	}
+	for _, e := range sm.edges() {
+		fmt.Fprintf(&b, "    %s --> %s : %s\n", ids[e.from], ids[e.to], mermaidEscaper.Replace(e.label()))
+	}
+
+	b.WriteString("    classDef current font-weight:bold,stroke-width:3px\n")
+	for _, state := range sm.regionStates() {
+		fmt.Fprintf(&b, "    class %s current\n", ids[state])
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package state52_test
+
+import (
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+func TestDOT(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(orderEvents),
+	)
+	sm.Event("pay", 150)
+
+	expected := `digraph state52 {
+	rankdir=LR;
+	"__start" [shape=point];
+	"cancelled";
+	"paid" [style=filled];
+	"shipped";
+	"unpaid";
+	"__start" -> "unpaid";
+	"unpaid" -> "cancelled" [label="cancel"];
+	"paid" -> "cancelled" [label="cancel"];
+	"unpaid" -> "paid" [label="pay [guarded]"];
+	"paid" -> "shipped" [label="ship [guarded]"];
+}
+`
+	if sm.DOT() != expected {
+		t.Errorf("expected DOT to be:\n%s\ngot:\n%s", expected, sm.DOT())
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(orderEvents),
+	)
+
+	expected := `stateDiagram-v2
+    state "cancelled" as s0
+    state "paid" as s1
+    state "shipped" as s2
+    state "unpaid" as s3
+    [*] --> s3
+    s3 --> s0 : cancel
+    s1 --> s0 : cancel
+    s3 --> s1 : pay [guarded]
+    s1 --> s2 : ship [guarded]
+    classDef current font-weight:bold,stroke-width:3px
+    class s3 current
+`
+	if sm.Mermaid() != expected {
+		t.Errorf("expected Mermaid to be:\n%s\ngot:\n%s", expected, sm.Mermaid())
+	}
+}
+
+func TestMermaidEscapesNames(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("in progress"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "finish",
+					Transitions: state52.Transitions{
+						{From: []string{"in progress"}, To: "done: #1", Guards: state52.Guards{state52.Named(`"approved"; signed`, "", fnThatReturnsTrue)}},
+					},
+				},
+			},
+		),
+	)
+
+	expected := `stateDiagram-v2
+    state "done#58; #35;1" as s0
+    state "in progress" as s1
+    [*] --> s1
+    s1 --> s0 : finish [#quot;approved#quot;#59; signed]
+    classDef current font-weight:bold,stroke-width:3px
+    class s1 current
+`
+	if sm.Mermaid() != expected {
+		t.Errorf("expected Mermaid to be:\n%s\ngot:\n%s", expected, sm.Mermaid())
+	}
+}
+
+func TestDOTEscapesNames(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("in\tprogress"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "finish",
+					Transitions: state52.Transitions{
+						{From: []string{"in\tprogress"}, To: `C:\done`, Guards: state52.Guards{state52.Named("\"approved\"\nsigned\x00", "", fnThatReturnsTrue)}},
+					},
+				},
+			},
+		),
+	)
+
+	expected := "digraph state52 {\n" +
+		"\trankdir=LR;\n" +
+		"\t\"__start\" [shape=point];\n" +
+		"\t\"C:\\\\done\";\n" +
+		"\t\"in progress\" [style=filled];\n" +
+		"\t\"__start\" -> \"in progress\";\n" +
+		"\t\"in progress\" -> \"C:\\\\done\" [label=\"finish [\\\"approved\\\"\\nsigned ]\"];\n" +
+		"}\n"
+	if sm.DOT() != expected {
+		t.Errorf("expected DOT to be:\n%s\ngot:\n%s", expected, sm.DOT())
+	}
+}
@@ -0,0 +1,196 @@
+package state52
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Guard is a condition that must be met for a transition to be performed.
+// Check is passed the event being fired, giving access to its Context() &
+// Args(). To reject the transition with a reason, return Reject(reason).
+// Any other error aborts the event with a GuardFailedError.
+//
+// GuardFunc, CheckFunc & Condition adapt functions to Guards.
+type Guard interface {
+	Check(*Event) (bool, error)
+}
+
+// Condition is a Guard that does not need the event, as guards
+// were originally written, e.g. Condition(order.IsPaid).
+type Condition func() bool
+
+// Check calls fn.
+func (fn Condition) Check(*Event) (bool, error) {
+	return fn(), nil
+}
+
+// GuardFunc is a Guard that returns true if the transition can be performed.
+type GuardFunc func(*Event) bool
+
+// Check calls fn.
+func (fn GuardFunc) Check(e *Event) (bool, error) {
+	return fn(e), nil
+}
+
+// CheckFunc is a Guard that can also return a Rejection or an error.
+type CheckFunc func(*Event) (bool, error)
+
+// Check calls fn.
+func (fn CheckFunc) Check(e *Event) (bool, error) {
+	return fn(e)
+}
+
+// Rejection is returned by a guard to reject a transition with a reason,
+// which is made available via CannotTransitionError.Candidates.
+type Rejection struct {
+	Reason string
+}
+
+func (r Rejection) Error() string {
+	return r.Reason
+}
+
+// Reject returns a Rejection with reason, e.g.
+//
+//	return false, state52.Reject("insufficient balance")
+func Reject(reason string) error {
+	return Rejection{reason}
+}
+
+// guardName returns the name of the guard: its Name() if it
+// has one, or else its index within the transition's guards.
+func guardName(guard Guard, index int) string {
+	if named, ok := guard.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("Guards[%d]", index)
+}
+
+// namedGuard is a Guard with a name & description, see Named.
+type namedGuard struct {
+	name        string
+	description string
+	guard       Guard
+}
+
+// Named gives guard a name, used by CannotTransitionError.Candidates,
+// GuardFailedError & the exporters, and a description of what it checks.
+func Named(name, description string, guard Guard) Guard {
+	return namedGuard{name, description, guard}
+}
+
+func (g namedGuard) Check(e *Event) (bool, error) {
+	return g.guard.Check(e)
+}
+
+// Name returns the guard's name.
+func (g namedGuard) Name() string {
+	return g.name
+}
+
+// Description returns the description of what the guard checks.
+func (g namedGuard) Description() string {
+	return g.description
+}
+
+// andGuard passes if all of its guards pass, see And.
+type andGuard []Guard
+
+// And returns a Guard that passes if all guards pass. Guards are checked in
+// order until one does not pass, whose Rejection (or, if it gave no reason,
+// its name as the reason) or error is returned.
+func And(guards ...Guard) Guard {
+	return andGuard(guards)
+}
+
+func (g andGuard) Check(e *Event) (bool, error) {
+	for _, guard := range g {
+		ok, err := guard.Check(e)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, Reject(nameOf(guard))
+		}
+	}
+	return true, nil
+}
+
+func (g andGuard) Name() string {
+	return "(" + joinNames(g, " && ") + ")"
+}
+
+// orGuard passes if any of its guards pass, see Or.
+type orGuard []Guard
+
+// Or returns a Guard that passes if any of guards pass. Guards are checked in
+// order until one passes. An error other than a Rejection is returned straight
+// away. If none pass, the reasons they gave are joined into a Rejection.
+func Or(guards ...Guard) Guard {
+	return orGuard(guards)
+}
+
+func (g orGuard) Check(e *Event) (bool, error) {
+	reasons := []string{}
+	for _, guard := range g {
+		ok, err := guard.Check(e)
+		var rejection Rejection
+		if errors.As(err, &rejection) {
+			reasons = append(reasons, rejection.Reason)
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+		reasons = append(reasons, nameOf(guard))
+	}
+	return false, Reject(strings.Join(reasons, "; "))
+}
+
+func (g orGuard) Name() string {
+	return "(" + joinNames(g, " || ") + ")"
+}
+
+// notGuard passes if its guard does not, see Not.
+type notGuard struct {
+	guard Guard
+}
+
+// Not returns a Guard that passes if guard is rejected.
+// An error other than a Rejection is returned.
+func Not(guard Guard) Guard {
+	return notGuard{guard}
+}
+
+func (g notGuard) Check(e *Event) (bool, error) {
+	ok, err := g.guard.Check(e)
+	var rejection Rejection
+	if err != nil && !errors.As(err, &rejection) {
+		return false, err
+	}
+	return !ok || err != nil, nil
+}
+
+func (g notGuard) Name() string {
+	return "!" + nameOf(g.guard)
+}
+
+// nameOf returns the name of the guard, or "guarded" if it has none.
+func nameOf(guard Guard) string {
+	if named, ok := guard.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return "guarded"
+}
+
+func joinNames(guards []Guard, sep string) string {
+	names := make([]string, len(guards))
+	for i, guard := range guards {
+		names[i] = nameOf(guard)
+	}
+	return strings.Join(names, sep)
+}
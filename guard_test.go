@@ -0,0 +1,168 @@
+package state52_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+var errDatabase = errors.New("connection refused")
+
+var sufficientBalance = state52.CheckFunc(func(e *state52.Event) (bool, error) {
+	if e.Args()[0].(int) < 100 {
+		return false, state52.Reject("insufficient balance")
+	}
+	return true, nil
+})
+
+var addressLookup = state52.CheckFunc(func(e *state52.Event) (bool, error) {
+	if e.Args()[1] == nil {
+		return false, errDatabase
+	}
+	return true, nil
+})
+
+var checkoutEvents = state52.Events{
+	{
+		Name: "checkout",
+		Transitions: state52.Transitions{
+			{From: []string{"cart"}, To: "paid", Guards: state52.Guards{sufficientBalance, addressLookup}},
+			{From: []string{"cart"}, To: "invoiced", Guards: state52.Guards{fnThatReturnsFalse}},
+		},
+	},
+}
+
+func TestGuardRejections(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("cart"),
+		state52.SetEvents(checkoutEvents),
+	)
+
+	err := sm.Event("checkout", 50, "London")
+
+	var cannotTransition state52.CannotTransitionError
+	if !errors.As(err, &cannotTransition) {
+		t.Fatalf("expected a CannotTransitionError, got %v", err)
+	}
+
+	expectedCandidates := []state52.Candidate{
+		{Index: 0, From: "cart", To: "paid", Guard: "Guards[0]", Reason: "insufficient balance"},
+		{Index: 1, From: "cart", To: "invoiced", Guard: "Guards[0]"},
+	}
+	if fmt.Sprint(cannotTransition.Candidates) != fmt.Sprint(expectedCandidates) {
+		t.Errorf("expected candidates to be %v, got %v", expectedCandidates, cannotTransition.Candidates)
+	}
+
+	expectedError := "Cannot transition from cart when calling checkout."
+	if err.Error() != expectedError {
+		t.Errorf("expected error message to be: %s, got %s", expectedError, err.Error())
+	}
+}
+
+func TestGuardFailed(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("cart"),
+		state52.SetEvents(checkoutEvents),
+	)
+
+	err := sm.Event("checkout", 150, nil)
+
+	expectedError := "Guard Guards[1] failed when calling checkout: connection refused."
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("expected error message to be: %s, got %v", expectedError, err)
+	}
+
+	if !errors.Is(err, errDatabase) {
+		t.Errorf("expected the error to wrap the guard's error, got %v", err)
+	}
+
+	if sm.CurrentState() != "cart" {
+		t.Errorf("expected state to be 'cart', got %s", sm.CurrentState())
+	}
+
+	err = sm.Event("checkout", 150, "London")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+}
+
+func TestGuardCombinators(t *testing.T) {
+	hasAddress := state52.Named("has_address", "The order has a shipping address.", fnThatReturnsTrue)
+	inStock := state52.Named("in_stock", "Every item is in stock.", fnThatReturnsFalse)
+	balance := state52.Named("sufficient_balance", "", state52.CheckFunc(func(e *state52.Event) (bool, error) {
+		return false, state52.Reject("insufficient balance")
+	}))
+	failing := state52.Named("lookup", "", state52.CheckFunc(func(e *state52.Event) (bool, error) {
+		return false, errDatabase
+	}))
+
+	tests := []struct {
+		guard          state52.Guard
+		expectedName   string
+		expectedOk     bool
+		expectedReason string
+	}{
+		{state52.And(hasAddress, inStock), "(has_address && in_stock)", false, "in_stock"},
+		{state52.And(hasAddress, balance), "(has_address && sufficient_balance)", false, "insufficient balance"},
+		{state52.Or(inStock, hasAddress), "(in_stock || has_address)", true, ""},
+		{state52.Or(inStock, balance), "(in_stock || sufficient_balance)", false, "in_stock; insufficient balance"},
+		{state52.Not(inStock), "!in_stock", true, ""},
+		{state52.Not(state52.Or(hasAddress, fnThatReturnsFalse)), "!(has_address || guarded)", false, ""},
+	}
+
+	for _, test := range tests {
+		if name := test.guard.(interface{ Name() string }).Name(); name != test.expectedName {
+			t.Errorf("expected name to be %s, got %s", test.expectedName, name)
+		}
+
+		ok, err := test.guard.Check(&state52.Event{})
+		var rejection state52.Rejection
+		errors.As(err, &rejection)
+		if ok != test.expectedOk || rejection.Reason != test.expectedReason {
+			t.Errorf("expected %s to return %t (%q), got %t (%v)", test.expectedName, test.expectedOk, test.expectedReason, ok, err)
+		}
+	}
+
+	for _, guard := range []state52.Guard{state52.And(hasAddress, failing), state52.Or(inStock, failing), state52.Not(failing)} {
+		_, err := guard.Check(&state52.Event{})
+		if !errors.Is(err, errDatabase) {
+			t.Errorf("expected the guard's error to be returned, got %v", err)
+		}
+	}
+}
+
+func TestNamedGuards(t *testing.T) {
+	hasAddress := state52.Named("has_address", "The order has a shipping address.", fnThatReturnsFalse)
+
+	sm := state52.MustNew(
+		state52.SetInitial("cart"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "checkout",
+					Transitions: state52.Transitions{
+						{From: []string{"cart"}, To: "paid", Guards: state52.Guards{state52.Or(hasAddress, state52.Not(fnThatReturnsTrue))}},
+					},
+				},
+			},
+		),
+	)
+
+	var cannotTransition state52.CannotTransitionError
+	if !errors.As(sm.Event("checkout"), &cannotTransition) || len(cannotTransition.Candidates) != 1 {
+		t.Fatalf("expected a CannotTransitionError with 1 candidate, got %v", cannotTransition)
+	}
+
+	candidate := cannotTransition.Candidates[0]
+	if candidate.Guard != "(has_address || !guarded)" || candidate.Reason != "has_address; !guarded" {
+		t.Errorf("expected the candidate to name the guard, got %v", candidate)
+	}
+
+	expectedLabel := `"cart" -> "paid" [label="checkout [(has_address || !guarded)]"];`
+	if !strings.Contains(sm.DOT(), expectedLabel) {
+		t.Errorf("expected DOT to include %s, got %s", expectedLabel, sm.DOT())
+	}
+}
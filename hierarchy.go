@@ -0,0 +1,273 @@
+package state52
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetSubstates makes parent a composite state containing substates. The first
+// substate is the initial substate, entered when a transition's To is parent.
+// A transition whose From includes parent can be performed from any of its
+// (nested) substates.
+func SetSubstates(parent string, substates ...string) SetupFunc {
+	return func(sm *State52) error {
+		if len(substates) == 0 {
+			return fmt.Errorf("%s must have at least 1 substate.", parent)
+		}
+
+		if sm.parents == nil {
+			sm.parents = map[string]string{}
+			sm.substates = map[string][]string{}
+		}
+
+		for _, substate := range substates {
+			if existing, ok := sm.parents[substate]; ok && existing != parent {
+				return fmt.Errorf("%s cannot be a substate of both %s and %s.", substate, existing, parent)
+			}
+			sm.parents[substate] = parent
+		}
+		sm.substates[parent] = append(sm.substates[parent], substates...)
+		return nil
+	}
+}
+
+// HistoryMode controls which substate is entered when a transition's To is a
+// composite state that has previously been exited.
+type HistoryMode int
+
+const (
+	// NoHistory enters the initial substate.
+	NoHistory HistoryMode = iota
+
+	// ShallowHistory enters the substate that was last active within To.
+	// If that substate is composite, its initial substate is entered.
+	ShallowHistory
+
+	// DeepHistory enters the (nested) substate that was last active within To.
+	DeepHistory
+)
+
+// CurrentStatePath returns the current state prefixed with the
+// composite states containing it, e.g. "processing.charging".
+// With regions it is the path in each region, as with CurrentState.
+func (sm *State52) CurrentStatePath() string {
+	paths := sm.regionStates()
+	for i, state := range paths {
+		paths[i] = strings.Join(sm.path(state), ".")
+	}
+	return sm.configuration(paths)
+}
+
+// In reports whether the state machine is in state, or in a substate of
+// it. With regions it reports whether any region is.
+func (sm *State52) In(state string) bool {
+	for _, current := range sm.regionStates() {
+		if sm.isIn(current, state) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIn reports whether current is state, or a substate of it.
+func (sm *State52) isIn(current, state string) bool {
+	if current == state {
+		return true
+	}
+	return stringInSlice(state, sm.ancestors(current))
+}
+
+// matchesFrom reports whether a transition from any of the
+// from states can be performed from currentState.
+func (sm *State52) matchesFrom(currentState string, from []string) bool {
+	for _, state := range from {
+		if sm.isIn(currentState, state) {
+			return true
+		}
+	}
+	return false
+}
+
+// ancestors returns the composite states containing state, innermost first.
+func (sm *State52) ancestors(state string) []string {
+	ancestors := []string{}
+	for parent, ok := sm.parents[state]; ok; parent, ok = sm.parents[parent] {
+		if stringInSlice(parent, ancestors) {
+			break // A cycle, reported by validate.
+		}
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// path returns state prefixed with the composite states containing it, outermost first.
+func (sm *State52) path(state string) []string {
+	ancestors := sm.ancestors(state)
+	path := make([]string, 0, len(ancestors)+1)
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		path = append(path, ancestors[i])
+	}
+	return append(path, state)
+}
+
+// resolveTarget returns the state that is entered when transitioning
+// to state, i.e. state's initial substate (recursively) if it is composite.
+func (sm *State52) resolveTarget(state string) string {
+	for i := 0; i <= len(sm.substates); i++ {
+		substates, ok := sm.substates[state]
+		if !ok {
+			break
+		}
+		state = substates[0]
+	}
+	return state
+}
+
+// historyTarget returns the state that is entered when transitioning to state
+// with the history mode, given the substates last active in each composite state.
+func (sm *State52) historyTarget(state string, mode HistoryMode, lastActive map[string]string) string {
+	switch mode {
+	case ShallowHistory:
+		if substate, ok := lastActive[state]; ok {
+			state = substate
+		}
+	case DeepHistory:
+		for i := 0; i <= len(lastActive); i++ {
+			substate, ok := lastActive[state]
+			if !ok {
+				break
+			}
+			state = substate
+		}
+	}
+	return sm.resolveTarget(state)
+}
+
+// remember returns a copy of the lastActive substates, updated with the
+// exited states, as they are the last active substates of their parents.
+func (sm *State52) remember(exited []string) map[string]string {
+	sm.stateMutex.RLock()
+	lastActive := copyLastActive(sm.lastActive)
+	sm.stateMutex.RUnlock()
+
+	for _, state := range exited {
+		if parent, ok := sm.parents[state]; ok {
+			if lastActive == nil {
+				lastActive = map[string]string{}
+			}
+			lastActive[parent] = state
+		}
+	}
+	return lastActive
+}
+
+func copyLastActive(lastActive map[string]string) map[string]string {
+	if lastActive == nil {
+		return nil
+	}
+
+	copied := make(map[string]string, len(lastActive))
+	for parent, substate := range lastActive {
+		copied[parent] = substate
+	}
+	return copied
+}
+
+// leaves returns state if it is not composite, or else all of its (nested) substates that are not.
+func (sm *State52) leaves(state string) []string {
+	return sm.leavesOf(state, map[string]struct{}{})
+}
+
+// leavesOf returns the leaves of state, skipping the states already visited.
+func (sm *State52) leavesOf(state string, visited map[string]struct{}) []string {
+	substates, ok := sm.substates[state]
+	if !ok {
+		return []string{state}
+	}
+	visited[state] = struct{}{}
+
+	leaves := []string{}
+	for _, substate := range substates {
+		if _, ok := visited[substate]; ok {
+			continue // A cycle, reported by validate.
+		}
+		leaves = append(leaves, sm.leavesOf(substate, visited)...)
+	}
+	return leaves
+}
+
+// exitAndEnter returns the states exited, innermost first, & the states
+// entered, outermost first, when transitioning from one state to another.
+// States containing both are neither exited nor entered. A transition
+// from a state to itself exits & re-enters it.
+func (sm *State52) exitAndEnter(from, to string) ([]string, []string) {
+	fromPath := sm.path(from)
+	toPath := sm.path(to)
+
+	common := 0
+	for common < len(fromPath) && common < len(toPath) && fromPath[common] == toPath[common] {
+		common++
+	}
+	if from == to {
+		common--
+	}
+
+	exit := []string{}
+	for i := len(fromPath) - 1; i >= common; i-- {
+		exit = append(exit, fromPath[i])
+	}
+	return exit, toPath[common:]
+}
+
+// validateSubstates returns a description of every problem found with the composite states.
+func (sm *State52) validateSubstates() []string {
+	problems := []string{}
+
+	for _, state := range sortedKeys(sm.parents) {
+		if stringInSlice(state, sm.ancestors(state)) {
+			problems = append(problems, fmt.Sprintf("%s cannot be a substate of itself.", state))
+		}
+	}
+
+	return problems
+}
+
+// validateHistory returns a description of every problem found with
+// transitions with History, whose lastActive substates are lost unless
+// they are persisted & restored with a Snapshot.
+func (sm *State52) validateHistory() []string {
+	problems := []string{}
+
+	for _, name := range sortedKeys(sm.events) {
+		hasHistory := false
+		for _, transition := range sm.events[name].Transitions {
+			hasHistory = hasHistory || transition.History != NoHistory
+		}
+		if !hasHistory {
+			continue
+		}
+
+		if sm.persistsState != "" {
+			problems = append(problems, fmt.Sprintf("%s has a transition with History, but %s only persists the state: use SetPersistSnapshotFn.", name, sm.persistsState))
+		}
+		if sm.restoresState != "" {
+			problems = append(problems, fmt.Sprintf("%s has a transition with History, but %s only restores the state: use SetSnapshot or SetLoadSnapshotFn.", name, sm.restoresState))
+		}
+	}
+
+	return problems
+}
+
+// validateLastActive returns a description of every
+// problem found with restored lastActive substates.
+func (sm *State52) validateLastActive(lastActive map[string]string) []string {
+	problems := []string{}
+
+	for _, parent := range sortedKeys(lastActive) {
+		if sm.parents[lastActive[parent]] != parent {
+			problems = append(problems, fmt.Sprintf("%s is not a substate of %s.", lastActive[parent], parent))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,339 @@
+package state52_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+var paymentEvents = state52.Events{
+	{
+		Name: "start",
+		Transitions: state52.Transitions{
+			{From: []string{"pending"}, To: "processing"},
+		},
+	},
+	{
+		Name: "validate",
+		Transitions: state52.Transitions{
+			{From: []string{"validating"}, To: "charging"},
+		},
+	},
+	{
+		Name: "cancel",
+		Transitions: state52.Transitions{
+			{From: []string{"processing"}, To: "cancelled"},
+		},
+	},
+}
+
+func TestSubstates(t *testing.T) {
+	order := []string{}
+	record := func(name string) func(sm *state52.State52, e *state52.Event, state string) error {
+		return func(sm *state52.State52, e *state52.Event, state string) error {
+			order = append(order, name+":"+state)
+			return nil
+		}
+	}
+
+	sm := state52.MustNew(
+		state52.SetInitial("pending"),
+		state52.SetSubstates("processing", "validating", "charging"),
+		state52.SetEvents(paymentEvents),
+		state52.SetGlobalStateCallbacks(state52.StateCallbacks{
+			"on_enter": record("enter"),
+			"on_exit":  record("exit"),
+		}),
+	)
+
+	err := sm.Event("start")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "validating" || sm.CurrentStatePath() != "processing.validating" {
+		t.Errorf("expected state to be 'processing.validating', got %s", sm.CurrentStatePath())
+	}
+
+	if !sm.In("processing") || sm.In("pending") {
+		t.Errorf("expected to be in 'processing' only")
+	}
+
+	sm.Event("validate")
+	if sm.CurrentStatePath() != "processing.charging" {
+		t.Errorf("expected state to be 'processing.charging', got %s", sm.CurrentStatePath())
+	}
+
+	// cancel is defined once on the parent & can be fired from any substate.
+	if fmt.Sprint(sm.AvailableEvents()) != "[cancel]" {
+		t.Errorf("expected available events to be [cancel], got %v", sm.AvailableEvents())
+	}
+
+	err = sm.Event("cancel")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentStatePath() != "cancelled" {
+		t.Errorf("expected state to be 'cancelled', got %s", sm.CurrentStatePath())
+	}
+
+	expectedOrder := []string{
+		"exit:pending", "enter:processing", "enter:validating",
+		"exit:validating", "enter:charging",
+		"exit:charging", "exit:processing", "enter:cancelled",
+	}
+	if fmt.Sprint(order) != fmt.Sprint(expectedOrder) {
+		t.Errorf("expected enter/exit order to be %v, got %v", expectedOrder, order)
+	}
+}
+
+func TestAnalyzeSubstates(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("pending"),
+		state52.SetSubstates("processing", "validating", "charging"),
+		state52.SetEvents(paymentEvents),
+	)
+
+	report := sm.Analyze()
+
+	if len(report.Unreachable) != 0 {
+		t.Errorf("expected no unreachable states, got %v", report.Unreachable)
+	}
+
+	if fmt.Sprint(report.Terminal) != "[cancelled]" {
+		t.Errorf("expected terminal states to be [cancelled], got %v", report.Terminal)
+	}
+}
+
+func TestAnalyzeShadowedBySuperstate(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("processing"),
+		state52.SetSubstates("processing", "validating", "charging"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "cancel",
+					Transitions: state52.Transitions{
+						{From: []string{"processing"}, To: "cancelled"},
+						{From: []string{"charging"}, To: "refunding"},
+						{From: []string{"cancelled"}, To: "validating"},
+					},
+				},
+				{
+					Name: "retry",
+					Transitions: state52.Transitions{
+						{From: []string{"charging"}, To: "validating"},
+						{From: []string{"processing"}, To: "validating"},
+					},
+				},
+			},
+		),
+	)
+
+	expectedShadowed := []state52.ShadowedTransition{
+		{EventName: "cancel", Index: 1, From: "charging", ShadowedBy: 0},
+	}
+	if report := sm.Analyze(); fmt.Sprint(report.Shadowed) != fmt.Sprint(expectedShadowed) {
+		t.Errorf("expected shadowed transitions to be %+v, got %+v", expectedShadowed, report.Shadowed)
+	}
+}
+
+func TestCompositeFinalState(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("pending"),
+		state52.SetSubstates("processing", "validating", "charging"),
+		state52.SetFinalStates("processing"),
+		state52.SetEvents(paymentEvents),
+	)
+
+	sm.Event("start")
+	if !sm.IsFinal() || sm.CurrentState() != "validating" {
+		t.Errorf("expected validating to be final, got %s", sm.CurrentState())
+	}
+
+	select {
+	case <-sm.Done():
+	default:
+		t.Errorf("expected the done channel to be closed")
+	}
+
+	err := sm.Event("validate")
+	var completedErr state52.MachineCompletedError
+	if !errors.As(err, &completedErr) {
+		t.Errorf("expected a MachineCompletedError, got %v", err)
+	}
+}
+
+func TestSubstateCycle(t *testing.T) {
+	_, err := state52.New(
+		state52.SetInitial("a"),
+		state52.SetSubstates("a", "b"),
+		state52.SetSubstates("b", "a"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"a"}, To: "c"},
+					},
+				},
+			},
+		),
+	)
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{"a cannot be a substate of itself.", "b cannot be a substate of itself."}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+}
+
+func TestSubstateCycleInRegion(t *testing.T) {
+	_, err := state52.New(
+		state52.SetRegion("r", "x"),
+		state52.SetSubstates("a", "b"),
+		state52.SetSubstates("b", "a"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"x"}, To: "a"},
+						{From: []string{"a"}, To: "x"},
+					},
+				},
+			},
+		),
+	)
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{"a cannot be a substate of itself.", "b cannot be a substate of itself."}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+}
+
+var workflowEvents = state52.Events{
+	{
+		Name: "start",
+		Transitions: state52.Transitions{
+			{From: []string{"draft"}, To: "running"},
+		},
+	},
+	{
+		Name: "review",
+		Transitions: state52.Transitions{
+			{From: []string{"drafting"}, To: "reviewing"},
+			{From: []string{"first_review"}, To: "second_review"},
+		},
+	},
+	{
+		Name: "pause",
+		Transitions: state52.Transitions{
+			{From: []string{"running"}, To: "paused"},
+		},
+	},
+	{
+		Name: "resume",
+		Transitions: state52.Transitions{
+			{From: []string{"paused"}, To: "running", History: state52.ShallowHistory},
+		},
+	},
+	{
+		Name: "resume_deep",
+		Transitions: state52.Transitions{
+			{From: []string{"paused"}, To: "running", History: state52.DeepHistory},
+		},
+	},
+	{
+		Name: "restart",
+		Transitions: state52.Transitions{
+			{From: []string{"paused"}, To: "running"},
+		},
+	},
+}
+
+func TestHistoryTransitions(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("draft"),
+		state52.SetSubstates("running", "drafting", "reviewing"),
+		state52.SetSubstates("reviewing", "first_review", "second_review"),
+		state52.SetEvents(workflowEvents),
+	)
+
+	// Resuming before running has been exited enters its initial substate.
+	for _, event := range []string{"start", "pause", "resume_deep"} {
+		sm.Event(event)
+	}
+	if sm.CurrentStatePath() != "running.drafting" {
+		t.Errorf("expected state to be 'running.drafting', got %s", sm.CurrentStatePath())
+	}
+
+	tests := []struct {
+		event    string
+		expected string
+	}{
+		{"resume_deep", "running.reviewing.second_review"},
+		{"resume", "running.reviewing.first_review"},
+		{"restart", "running.drafting"},
+	}
+
+	for _, test := range tests {
+		sm := state52.MustNew(
+			state52.SetInitial("draft"),
+			state52.SetSubstates("running", "drafting", "reviewing"),
+			state52.SetSubstates("reviewing", "first_review", "second_review"),
+			state52.SetEvents(workflowEvents),
+		)
+		for _, event := range []string{"start", "review", "review", "pause", test.event} {
+			sm.Event(event)
+		}
+
+		if sm.CurrentStatePath() != test.expected {
+			t.Errorf("expected %s to enter '%s', got %s", test.event, test.expected, sm.CurrentStatePath())
+		}
+	}
+}
+
+func TestHistoryNeedsSnapshotPersistence(t *testing.T) {
+	_, err := state52.New(
+		state52.SetInitial("paused"),
+		state52.SetSubstates("running", "drafting", "reviewing"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "resume",
+					Transitions: state52.Transitions{
+						{From: []string{"paused"}, To: "running", History: state52.ShallowHistory},
+					},
+				},
+			},
+		),
+		state52.SetPersistFn(func(newState string) error { return nil }),
+		state52.SetLoadFn(func() (string, error) { return "", nil }),
+	)
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{
+		"resume has a transition with History, but SetPersistFn only persists the state: use SetPersistSnapshotFn.",
+		"resume has a transition with History, but SetLoadFn only restores the state: use SetSnapshot or SetLoadSnapshotFn.",
+	}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+}
@@ -0,0 +1,70 @@
+package state52
+
+import (
+	"time"
+)
+
+// Record describes a single firing of an event.
+type Record struct {
+	EventName string
+
+	// From is the state the event was fired in.
+	From string
+
+	// To is the state the event transitioned to. It is
+	// empty if the event did not perform a transition.
+	To string
+
+	Args     []interface{}
+	Time     time.Time
+	Duration time.Duration
+
+	// Err is the error the event returned, if any.
+	Err error
+}
+
+// HistorySink receives a Record for every event fired,
+// e.g. to write them to an audit store.
+type HistorySink interface {
+	WriteRecord(Record)
+}
+
+// SetHistory keeps a history of the last limit events fired, available via History().
+func SetHistory(limit int) SetupFunc {
+	return func(sm *State52) error {
+		sm.historyLimit = limit
+		return nil
+	}
+}
+
+// SetHistorySink sets a HistorySink that receives a Record for every event fired.
+func SetHistorySink(sink HistorySink) SetupFunc {
+	return func(sm *State52) error {
+		sm.historySink = sink
+		return nil
+	}
+}
+
+// History returns the most recent events fired, oldest first.
+// It is empty unless SetHistory has been used.
+func (sm *State52) History() []Record {
+	sm.historyMutex.RLock()
+	defer sm.historyMutex.RUnlock()
+	return append([]Record{}, sm.history...)
+}
+
+// record adds r to the history & writes it to the historySink.
+func (sm *State52) record(r Record) {
+	if sm.historyLimit > 0 {
+		sm.historyMutex.Lock()
+		sm.history = append(sm.history, r)
+		if len(sm.history) > sm.historyLimit {
+			sm.history = sm.history[len(sm.history)-sm.historyLimit:]
+		}
+		sm.historyMutex.Unlock()
+	}
+
+	if sm.historySink != nil {
+		sm.historySink.WriteRecord(r)
+	}
+}
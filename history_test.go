@@ -0,0 +1,71 @@
+package state52_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+type recordingSink struct {
+	records []state52.Record
+}
+
+func (s *recordingSink) WriteRecord(r state52.Record) {
+	s.records = append(s.records, r)
+}
+
+func TestHistory(t *testing.T) {
+	sink := &recordingSink{}
+
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetHistory(2),
+		state52.SetHistorySink(sink),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "pay",
+					Transitions: state52.Transitions{
+						{From: []string{"unpaid"}, To: "paid"},
+					},
+				},
+				{
+					Name: "ship",
+					Transitions: state52.Transitions{
+						{From: []string{"paid"}, To: "shipped"},
+					},
+				},
+			},
+		),
+	)
+
+	sm.Event("ship")
+	sm.Event("pay", 150)
+	sm.Event("ship")
+
+	if len(sink.records) != 3 {
+		t.Fatalf("expected the sink to receive 3 records, got %d", len(sink.records))
+	}
+
+	if sink.records[0].Err == nil || sink.records[0].To != "" {
+		t.Errorf("expected the first record to be a failed event, got %+v", sink.records[0])
+	}
+
+	history := sm.History()
+	if len(history) != 2 {
+		t.Fatalf("expected history to hold the last 2 records, got %d", len(history))
+	}
+
+	got := fmt.Sprintf("%s %s->%s %v, %s %s->%s %v",
+		history[0].EventName, history[0].From, history[0].To, history[0].Args,
+		history[1].EventName, history[1].From, history[1].To, history[1].Args)
+	expected := "pay unpaid->paid [150], ship paid->shipped []"
+	if got != expected {
+		t.Errorf("expected history to be: %s, got %s", expected, got)
+	}
+
+	if history[0].Time.IsZero() || history[0].Err != nil {
+		t.Errorf("expected a timestamped, successful record, got %+v", history[0])
+	}
+}
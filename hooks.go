@@ -0,0 +1,142 @@
+package state52
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Hook is a named callback, one of several that can be run for the
+// same Global or Event callback (e.g. "after"). Hooks run in ascending
+// Priority order; hooks with equal priority run in the order defined.
+type Hook struct {
+	Name     string
+	Priority int
+	Fn       callback
+}
+
+// Hooks -> Syntax for building the state machine
+type Hooks map[string][]Hook
+
+// TransitionHook is a named Transition callback, one of several
+// that can be run for the same callback (e.g. "success").
+type TransitionHook struct {
+	Name     string
+	Priority int
+	Fn       tCallback
+}
+
+// TransitionHooks -> Syntax for building the state machine
+type TransitionHooks map[string][]TransitionHook
+
+// StateHook is a named State callback, one of several
+// that can be run for the same callback (e.g. "on_enter").
+type StateHook struct {
+	Name     string
+	Priority int
+	Fn       sCallback
+}
+
+// StateHooks -> Syntax for building the state machine
+type StateHooks map[string][]StateHook
+
+// SetGlobalHooks sets any 'global' hooks you may seek to add.
+// They are run alongside any set with SetGlobalCallbacks.
+func SetGlobalHooks(hooks Hooks) SetupFunc {
+	return func(sm *State52) error {
+		sm.globalHooks = hooks
+		return nil
+	}
+}
+
+// hook is implemented by Hook, TransitionHook & StateHook.
+type hook interface {
+	name() string
+	priority() int
+	hasFn() bool
+}
+
+func (h Hook) name() string            { return h.Name }
+func (h Hook) priority() int           { return h.Priority }
+func (h Hook) hasFn() bool             { return h.Fn != nil }
+func (h TransitionHook) name() string  { return h.Name }
+func (h TransitionHook) priority() int { return h.Priority }
+func (h TransitionHook) hasFn() bool   { return h.Fn != nil }
+func (h StateHook) name() string       { return h.Name }
+func (h StateHook) priority() int      { return h.Priority }
+func (h StateHook) hasFn() bool        { return h.Fn != nil }
+
+// sortHooks sorts each hook chain by Priority, keeping the defined order for equal priorities.
+func sortHooks[H hook](chains map[string][]H) map[string][]H {
+	for _, chain := range chains {
+		sort.SliceStable(chain, func(i, j int) bool {
+			return chain[i].priority() < chain[j].priority()
+		})
+	}
+	return chains
+}
+
+// chainable is implemented by Hook, TransitionHook & StateHook,
+// whose Fn is of type F.
+type chainable[F any] interface {
+	~struct {
+		Name     string
+		Priority int
+		Fn       F
+	}
+	hook
+}
+
+// chainHooks merges the single callback per key with the hooks for the key.
+// The single callback is named after its key, has priority 0 & runs before
+// any hooks of equal priority.
+func chainHooks[F any, H chainable[F]](callbacks map[string]F, hooks map[string][]H) map[string][]H {
+	chains := map[string][]H{}
+	for key, fn := range callbacks {
+		chains[key] = append(chains[key], H{Name: key, Fn: fn})
+	}
+	for key, list := range hooks {
+		chains[key] = append(chains[key], list...)
+	}
+	return sortHooks(chains)
+}
+
+// validateHooks returns a description of every problem found with the hook chains.
+// kind describes the chains, e.g. "Event Callback".
+func validateHooks[H hook](chains map[string][]H, valid []string, kind string) []string {
+	problems := []string{}
+
+	for _, key := range sortedKeys(chains) {
+		if !stringInSlice(key, valid) {
+			problems = append(problems, fmt.Sprintf("%s is not a valid %s. The following are valid: %s.", key, kind, strings.Join(valid, ",")))
+			continue
+		}
+
+		seen := map[string]struct{}{}
+		for _, h := range chains[key] {
+			if _, ok := seen[h.name()]; ok || h.name() == "" {
+				problems = append(problems, fmt.Sprintf("%s hooks must have unique, non-empty names: got %q.", key, h.name()))
+			}
+			if !h.hasFn() {
+				problems = append(problems, fmt.Sprintf("%s hook %q has no Fn.", key, h.name()))
+			}
+			seen[h.name()] = struct{}{}
+		}
+	}
+
+	return problems
+}
+
+// runHooks calls each hook in order, using call. If abort is set the first error
+// is returned straight away, otherwise every hook is called & errors are joined.
+func runHooks[H hook](chain []H, abort bool, call func(H) error) error {
+	errs := []error{}
+	for _, hook := range chain {
+		err := call(hook)
+		if err != nil && abort {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return joinErrors(errs...)
+}
@@ -0,0 +1,272 @@
+package state52_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+func TestHooksRunInPriorityOrder(t *testing.T) {
+	order := []string{}
+	hook := func(name string, priority int) state52.Hook {
+		return state52.Hook{Name: name, Priority: priority, Fn: func(sm *state52.State52, e *state52.Event) error {
+			order = append(order, name)
+			return nil
+		}}
+	}
+	transitionHook := func(name string, priority int) state52.TransitionHook {
+		return state52.TransitionHook{Name: name, Priority: priority, Fn: func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+			order = append(order, name)
+			return nil
+		}}
+	}
+
+	sm := state52.MustNew(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first",
+							Hooks: state52.TransitionHooks{
+								"success": {transitionHook("notify", 0), transitionHook("metrics", -1)},
+							},
+						},
+					},
+					Callbacks: state52.Callbacks{
+						"after": func(sm *state52.State52, e *state52.Event) error {
+							order = append(order, "business_logic")
+							return nil
+						},
+					},
+					Hooks: state52.Hooks{
+						"after": {hook("audit", 10), hook("logging", -10), hook("cache", 0)},
+					},
+				},
+			},
+		),
+		state52.SetGlobalHooks(
+			state52.Hooks{
+				"after_all_events": {hook("global_second", 2), hook("global_first", 1)},
+			},
+		),
+	)
+
+	err := sm.Event("first_event")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	expectedOrder := []string{"metrics", "notify", "logging", "business_logic", "cache", "audit", "global_first", "global_second"}
+	if fmt.Sprint(order) != fmt.Sprint(expectedOrder) {
+		t.Errorf("expected hook order to be %v, got %v", expectedOrder, order)
+	}
+}
+
+func TestBeforeHookErrorStopsLaterHooks(t *testing.T) {
+	beforeErr := errors.New("not allowed")
+	laterCalled := false
+
+	sm := state52.MustNew(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+					Hooks: state52.Hooks{
+						"before": {
+							{Name: "authorize", Fn: func(sm *state52.State52, e *state52.Event) error {
+								return beforeErr
+							}},
+							{Name: "later", Priority: 1, Fn: func(sm *state52.State52, e *state52.Event) error {
+								laterCalled = true
+								return nil
+							}},
+						},
+					},
+				},
+			},
+		),
+	)
+
+	err := sm.Event("first_event")
+	if err != beforeErr {
+		t.Errorf("expected error to be: %s, got %v", beforeErr, err)
+	}
+
+	if laterCalled {
+		t.Errorf("expected later before hooks not to be called")
+	}
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
+	}
+}
+
+func TestInvalidHooks(t *testing.T) {
+	noop := func(sm *state52.State52, e *state52.Event) error { return nil }
+
+	_, err := state52.New(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+					Callbacks: state52.Callbacks{"after": noop},
+					Hooks: state52.Hooks{
+						"after":          {{Name: "after", Fn: noop}, {Name: "missing_fn"}},
+						"not_a_callback": {{Name: "audit", Fn: noop}},
+					},
+				},
+			},
+		),
+	)
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{
+		`after hooks must have unique, non-empty names: got "after".`,
+		`after hook "missing_fn" has no Fn.`,
+		"not_a_callback is not a valid Event Callback. The following are valid: before,after,ensure.",
+	}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+}
+
+func TestStateCallbacks(t *testing.T) {
+	order := []string{}
+	record := func(name string) func(sm *state52.State52, e *state52.Event, state string) error {
+		return func(sm *state52.State52, e *state52.Event, state string) error {
+			order = append(order, name+":"+state)
+			return nil
+		}
+	}
+
+	sm := state52.MustNew(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "shipped",
+							Callbacks: state52.TransitionCallbacks{
+								"after": func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+									order = append(order, "transition_after")
+									return nil
+								},
+								"success": func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+									order = append(order, "transition_success")
+									return nil
+								},
+							},
+						},
+					},
+				},
+			},
+		),
+		state52.SetPersistFn(func(newState string) error {
+			order = append(order, "persist")
+			return nil
+		}),
+		state52.SetStateCallbacks("start", state52.StateCallbacks{"on_exit": record("on_exit")}),
+		state52.SetStateCallbacks("shipped", state52.StateCallbacks{"on_enter": record("on_enter")}),
+		state52.SetStateHooks("shipped", state52.StateHooks{
+			"on_enter": {{Name: "send_email", Priority: 1, Fn: record("send_email")}},
+		}),
+		state52.SetGlobalStateCallbacks(state52.StateCallbacks{
+			"on_enter": record("global_on_enter"),
+			"on_exit":  record("global_on_exit"),
+		}),
+	)
+
+	err := sm.Event("first_event")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	expectedOrder := []string{
+		"transition_after", "on_exit:start", "global_on_exit:start", "persist",
+		"on_enter:shipped", "send_email:shipped", "global_on_enter:shipped", "transition_success",
+	}
+	if fmt.Sprint(order) != fmt.Sprint(expectedOrder) {
+		t.Errorf("expected callback order to be %v, got %v", expectedOrder, order)
+	}
+}
+
+func TestStateExitErrorAbortsEvent(t *testing.T) {
+	exitErr := errors.New("cannot leave")
+
+	sm := state52.MustNew(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+			},
+		),
+		state52.SetStateCallbacks("start", state52.StateCallbacks{
+			"on_exit": func(sm *state52.State52, e *state52.Event, state string) error {
+				return exitErr
+			},
+		}),
+	)
+
+	err := sm.Event("first_event")
+	if err != exitErr {
+		t.Errorf("expected error to be: %s, got %v", exitErr, err)
+	}
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
+	}
+}
+
+func TestStateCallbacksForUnregisteredState(t *testing.T) {
+	_, err := state52.New(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+			},
+		),
+		state52.SetStateCallbacks("not_a_state", state52.StateCallbacks{
+			"on_entry": func(sm *state52.State52, e *state52.Event, state string) error { return nil },
+		}),
+	)
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{
+		"State callbacks are set for not_a_state, which was not found in the registered states.",
+		"on_entry is not a valid State Callback. The following are valid: on_enter,on_exit.",
+	}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+}
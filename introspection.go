@@ -0,0 +1,91 @@
+package state52
+
+import (
+	"context"
+	"sort"
+)
+
+// States returns all registered states, sorted by name.
+func (sm *State52) States() []string {
+	return sortedKeys(sm.states)
+}
+
+// Events returns the names of all registered events, sorted by name.
+func (sm *State52) Events() []string {
+	return sortedKeys(sm.events)
+}
+
+// Transitions returns the transitions defined for an event, including
+// any event level guards. It returns nil if the event is not registered.
+// The transitions are copies, so changing them does not change the state machine.
+func (sm *State52) Transitions(event string) []Transition {
+	e, ok := sm.events[event]
+	if !ok {
+		return nil
+	}
+
+	transitions := make([]Transition, len(e.Transitions))
+	for i, transition := range e.Transitions {
+		transition.From = append([]string{}, transition.From...)
+		transition.Guards = append([]Guard{}, transition.Guards...)
+		transitions[i] = transition
+	}
+	return transitions
+}
+
+// AvailableEvents returns the names of the events with at least one
+// transition from the current state (of any region), sorted by name.
+// Guards are not evaluated.
+// No events are available once the state machine is in a final state.
+func (sm *State52) AvailableEvents() []string {
+	available := []string{}
+	if sm.IsFinal() {
+		return available
+	}
+
+	currentStates := sm.regionStates()
+	for name, e := range sm.events {
+		if sm.canPerform(e, currentStates) {
+			available = append(available, name)
+		}
+	}
+
+	sort.Strings(available)
+	return available
+}
+
+// Can reports whether the event can be fired from the current state with
+// args, i.e. whether it has a transition (in any region) whose guards all return true.
+// No callbacks are called & the state is not changed.
+//
+// Can does not wait for an event being fired to complete, so it answers for
+// the state the state machine is in until that event's new state is set.
+// As guards may be evaluated by Can at any time, they must not have side effects.
+func (sm *State52) Can(event string, args ...interface{}) bool {
+	e, ok := sm.events[event]
+	if !ok || sm.IsFinal() {
+		return false
+	}
+	e.ctx = context.Background()
+	e.args = args
+	e.machine = sm.machine
+
+	selected, _, err := sm.selectTransitions(&e, sm.regionStates(), nil)
+	return len(selected) > 0 && err == nil
+}
+
+// canPerform reports whether the event has a transition, that is not
+// timed, from any of the currentStates.
+func (sm *State52) canPerform(e Event, currentStates []string) bool {
+	for _, transition := range e.Transitions {
+		if transition.After > 0 {
+			continue
+		}
+		for _, state := range currentStates {
+			if sm.matchesFrom(state, transition.From) {
+				return true
+			}
+		}
+	}
+	return false
+}
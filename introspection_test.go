@@ -0,0 +1,138 @@
+package state52_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+var sufficientAmount = state52.GuardFunc(func(e *state52.Event) bool {
+	return len(e.Args()) > 0 && e.Args()[0].(int) >= 100
+})
+
+var orderEvents = state52.Events{
+	{
+		Name: "pay",
+		Transitions: state52.Transitions{
+			{From: []string{"unpaid"}, To: "paid", Guards: state52.Guards{sufficientAmount}},
+		},
+	},
+	{
+		Name: "cancel",
+		Transitions: state52.Transitions{
+			{From: []string{"unpaid", "paid"}, To: "cancelled"},
+		},
+	},
+	{
+		Name:   "ship",
+		Guards: state52.Guards{fnThatReturnsTrue},
+		Transitions: state52.Transitions{
+			{From: []string{"paid"}, To: "shipped"},
+		},
+	},
+}
+
+func TestStatesAndEvents(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(orderEvents),
+	)
+
+	expectedStates := []string{"cancelled", "paid", "shipped", "unpaid"}
+	if fmt.Sprint(sm.States()) != fmt.Sprint(expectedStates) {
+		t.Errorf("expected states to be %v, got %v", expectedStates, sm.States())
+	}
+
+	expectedEvents := []string{"cancel", "pay", "ship"}
+	if fmt.Sprint(sm.Events()) != fmt.Sprint(expectedEvents) {
+		t.Errorf("expected events to be %v, got %v", expectedEvents, sm.Events())
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(orderEvents),
+	)
+
+	transitions := sm.Transitions("ship")
+	if len(transitions) != 1 || transitions[0].To != "shipped" || len(transitions[0].Guards) != 1 {
+		t.Errorf("expected 1 guarded transition to shipped, got %+v", transitions)
+	}
+
+	transitions[0].From[0] = "unpaid"
+	transitions[0].Guards[0] = fnThatReturnsFalse
+	if from := sm.Transitions("ship")[0].From[0]; from != "paid" {
+		t.Errorf("expected changing the returned From not to change the state machine, got %s", from)
+	}
+	if ok, _ := sm.Transitions("ship")[0].Guards[0].Check(nil); !ok {
+		t.Errorf("expected changing the returned Guards not to change the state machine")
+	}
+
+	if sm.Transitions("not_an_event") != nil {
+		t.Errorf("expected no transitions for an unregistered event")
+	}
+}
+
+func TestAvailableEventsAndCan(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(orderEvents),
+	)
+
+	expectedEvents := []string{"cancel", "pay"}
+	if fmt.Sprint(sm.AvailableEvents()) != fmt.Sprint(expectedEvents) {
+		t.Errorf("expected available events to be %v, got %v", expectedEvents, sm.AvailableEvents())
+	}
+
+	if sm.Can("pay", 50) {
+		t.Errorf("expected pay with 50 not to be possible")
+	}
+
+	if !sm.Can("pay", 150) {
+		t.Errorf("expected pay with 150 to be possible")
+	}
+
+	if sm.Can("ship") || sm.Can("not_an_event") {
+		t.Errorf("expected ship & not_an_event not to be possible")
+	}
+
+	if sm.CurrentState() != "unpaid" {
+		t.Errorf("expected state to be 'unpaid', got %s", sm.CurrentState())
+	}
+}
+
+func TestCanDoesNotWaitForEvents(t *testing.T) {
+	persisting := make(chan struct{})
+	release := make(chan struct{})
+
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(orderEvents),
+		state52.SetPersistFn(func(newState string) error {
+			close(persisting)
+			<-release
+			return nil
+		}),
+	)
+
+	done := make(chan error)
+	go func() {
+		done <- sm.Event("pay", 150)
+	}()
+	<-persisting
+
+	if !sm.Can("pay", 150) || sm.Can("ship") {
+		t.Errorf("expected Can to answer for unpaid while pay is being persisted")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.Can("pay", 150) || !sm.Can("ship") {
+		t.Errorf("expected Can to answer for paid once pay has completed")
+	}
+}
@@ -0,0 +1,37 @@
+package state52
+
+import "context"
+
+// eventLock ensures events are fired one at a time. Unlike a sync.Mutex,
+// waiting for it can be abandoned when a context is done.
+type eventLock struct {
+	sem chan struct{}
+}
+
+func newEventLock() eventLock {
+	return eventLock{sem: make(chan struct{}, 1)}
+}
+
+// lock waits for the eventLock.
+func (l *eventLock) lock() {
+	l.sem <- struct{}{}
+}
+
+// lockContext waits for the eventLock, returning ctx.Err() if ctx is done first.
+func (l *eventLock) lockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unlock releases the eventLock.
+func (l *eventLock) unlock() {
+	<-l.sem
+}
@@ -0,0 +1,77 @@
+package state52
+
+// Observer is notified as events are fired. Observers are
+// added at runtime with AddObserver, which returns a fn to remove them.
+// Embed NopObserver to only implement the methods you need.
+type Observer interface {
+	// OnBeforeEvent is called when an event is fired, before any callbacks.
+	OnBeforeEvent(sm *State52, e *Event)
+
+	// OnTransition is called once the new state has been set.
+	OnTransition(sm *State52, e *Event, from, to string)
+
+	// OnRejected is called when an event is aborted before a transition
+	// is selected or performed, e.g. because no transition could be
+	// selected, a before callback returned an error or ctx was done.
+	OnRejected(sm *State52, e *Event, err error)
+
+	// OnPersistFailed is called when the persistFn returns an error.
+	OnPersistFailed(sm *State52, e *Event, err PersistFailedError)
+
+	// OnEnsure is called after the ensure callbacks,
+	// with the error the event will return (if any).
+	OnEnsure(sm *State52, e *Event, err error)
+}
+
+// NopObserver implements every Observer method as a no-op.
+type NopObserver struct{}
+
+// OnBeforeEvent does nothing.
+func (NopObserver) OnBeforeEvent(sm *State52, e *Event) {}
+
+// OnTransition does nothing.
+func (NopObserver) OnTransition(sm *State52, e *Event, from, to string) {}
+
+// OnRejected does nothing.
+func (NopObserver) OnRejected(sm *State52, e *Event, err error) {}
+
+// OnPersistFailed does nothing.
+func (NopObserver) OnPersistFailed(sm *State52, e *Event, err PersistFailedError) {}
+
+// OnEnsure does nothing.
+func (NopObserver) OnEnsure(sm *State52, e *Event, err error) {}
+
+// AddObserver adds an observer, to be notified of every event fired from now on.
+// It returns a fn that removes the observer again. As each fn removes only the
+// observer it was returned for, observers need not be comparable (or pointers).
+func (sm *State52) AddObserver(o Observer) (remove func()) {
+	sm.observersMutex.Lock()
+	defer sm.observersMutex.Unlock()
+
+	added := &o
+	sm.observers = append(sm.observers, added)
+
+	return func() {
+		sm.observersMutex.Lock()
+		defer sm.observersMutex.Unlock()
+
+		observers := []*Observer{}
+		for _, observer := range sm.observers {
+			if observer != added {
+				observers = append(observers, observer)
+			}
+		}
+		sm.observers = observers
+	}
+}
+
+// notify calls fn for every observer.
+func (sm *State52) notify(fn func(Observer)) {
+	sm.observersMutex.RLock()
+	observers := sm.observers
+	sm.observersMutex.RUnlock()
+
+	for _, observer := range observers {
+		fn(*observer)
+	}
+}
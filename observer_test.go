@@ -0,0 +1,132 @@
+package state52_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+type recordingObserver struct {
+	state52.NopObserver
+	calls []string
+}
+
+func (o *recordingObserver) OnBeforeEvent(sm *state52.State52, e *state52.Event) {
+	o.calls = append(o.calls, "before:"+e.Name)
+}
+
+func (o *recordingObserver) OnTransition(sm *state52.State52, e *state52.Event, from, to string) {
+	o.calls = append(o.calls, "transition:"+from+"->"+to)
+}
+
+func (o *recordingObserver) OnRejected(sm *state52.State52, e *state52.Event, err error) {
+	o.calls = append(o.calls, "rejected:"+e.Name)
+}
+
+func (o *recordingObserver) OnPersistFailed(sm *state52.State52, e *state52.Event, err state52.PersistFailedError) {
+	o.calls = append(o.calls, "persist_failed:"+err.To)
+}
+
+func (o *recordingObserver) OnEnsure(sm *state52.State52, e *state52.Event, err error) {
+	o.calls = append(o.calls, fmt.Sprintf("ensure:%s:%t", e.Name, err == nil))
+}
+
+func TestObservers(t *testing.T) {
+	failPersist := false
+
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "pay",
+					Transitions: state52.Transitions{
+						{From: []string{"unpaid"}, To: "paid"},
+					},
+				},
+				{
+					Name: "ship",
+					Transitions: state52.Transitions{
+						{From: []string{"paid"}, To: "shipped"},
+					},
+				},
+			},
+		),
+		state52.SetPersistFn(func(newState string) error {
+			if failPersist {
+				return errors.New("database unavailable")
+			}
+			return nil
+		}),
+	)
+
+	first := &recordingObserver{}
+	second := &recordingObserver{}
+	sm.AddObserver(first)
+	removeSecond := sm.AddObserver(second)
+
+	sm.Event("ship")
+	sm.Event("pay")
+
+	removeSecond()
+
+	failPersist = true
+	sm.Event("ship")
+
+	expectedFirst := []string{
+		"before:ship", "rejected:ship", "ensure:ship:false",
+		"before:pay", "transition:unpaid->paid", "ensure:pay:true",
+		"before:ship", "persist_failed:shipped", "ensure:ship:false",
+	}
+	if fmt.Sprint(first.calls) != fmt.Sprint(expectedFirst) {
+		t.Errorf("expected first observer calls to be %v, got %v", expectedFirst, first.calls)
+	}
+
+	expectedSecond := expectedFirst[:6]
+	if fmt.Sprint(second.calls) != fmt.Sprint(expectedSecond) {
+		t.Errorf("expected second observer calls to be %v, got %v", expectedSecond, second.calls)
+	}
+}
+
+// transitionsObserver is a value observer that is not comparable, as it holds a map.
+type transitionsObserver struct {
+	state52.NopObserver
+	transitions map[string]int
+}
+
+func (o transitionsObserver) OnTransition(sm *state52.State52, e *state52.Event, from, to string) {
+	o.transitions[e.Name]++
+}
+
+func TestRemovingUncomparableObserver(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "toggle",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "end"},
+						{From: []string{"end"}, To: "start"},
+					},
+				},
+			},
+		),
+	)
+
+	first := transitionsObserver{transitions: map[string]int{}}
+	second := transitionsObserver{transitions: map[string]int{}}
+	removeFirst := sm.AddObserver(first)
+	sm.AddObserver(second)
+
+	sm.Event("toggle")
+	removeFirst()
+	removeFirst()
+	sm.Event("toggle")
+
+	if first.transitions["toggle"] != 1 || second.transitions["toggle"] != 2 {
+		t.Errorf("expected 1 & 2 transitions to be observed, got %v & %v", first.transitions, second.transitions)
+	}
+}
@@ -0,0 +1,141 @@
+package state52
+
+import (
+	"fmt"
+	"strings"
+)
+
+// region is an orthogonal region of the state machine, with its own
+// current state. A state machine without regions has a single region,
+// named "", that starts in the initialState.
+type region struct {
+	name    string
+	initial string
+}
+
+// SetRegion adds an orthogonal (parallel) region to the state machine,
+// starting in initial. Each region has its own current state & an event is
+// performed in every region with a transition from its current state.
+// A state machine with regions must not also SetInitial.
+func SetRegion(name string, initial string) SetupFunc {
+	return func(sm *State52) error {
+		sm.regions = append(sm.regions, region{name, initial})
+		return nil
+	}
+}
+
+// Configuration returns the current state of each region, keyed by the
+// region's name. A state machine without regions has a single region named "".
+func (sm *State52) Configuration() map[string]string {
+	configuration := map[string]string{}
+	for i, state := range sm.regionStates() {
+		configuration[sm.regions[i].name] = state
+	}
+	return configuration
+}
+
+// regionStates returns the current state of each region.
+func (sm *State52) regionStates() []string {
+	sm.stateMutex.RLock()
+	defer sm.stateMutex.RUnlock()
+	return append([]string{}, sm.currentStates...)
+}
+
+// hasRegions reports whether the state machine was set up with regions.
+func (sm *State52) hasRegions() bool {
+	return len(sm.regions) > 1 || (len(sm.regions) == 1 && sm.regions[0].name != "")
+}
+
+// configuration formats the state of each region as returned by CurrentState,
+// e.g. "payment:paid,fulfillment:packed". Without regions it is the state.
+func (sm *State52) configuration(states []string) string {
+	if !sm.hasRegions() {
+		return states[0]
+	}
+
+	parts := make([]string, len(states))
+	for i, state := range states {
+		parts[i] = sm.regions[i].name + ":" + state
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseConfiguration parses a configuration formatted by configuration,
+// returning the state of each region & a description of every problem found.
+func (sm *State52) parseConfiguration(configuration string) ([]string, []string) {
+	if !sm.hasRegions() {
+		return []string{configuration}, nil
+	}
+
+	problems := []string{}
+	byName := map[string]string{}
+	for _, part := range strings.Split(configuration, ",") {
+		name, state, ok := strings.Cut(part, ":")
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a region:state pair.", part))
+			continue
+		}
+		byName[name] = state
+	}
+
+	states := make([]string, len(sm.regions))
+	for i, region := range sm.regions {
+		state, ok := byName[region.name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s has no state for the %s region.", configuration, region.name))
+			continue
+		}
+		states[i] = state
+		delete(byName, region.name)
+	}
+
+	for _, name := range sortedKeys(byName) {
+		problems = append(problems, fmt.Sprintf("%s is not a registered region.", name))
+	}
+
+	return states, problems
+}
+
+// validateRegions returns a description of every problem found with the regions.
+// Unless checkReachable, states are not checked to be in a single region.
+func (sm *State52) validateRegions(checkReachable bool) []string {
+	problems := []string{}
+
+	if sm.initialState != "" {
+		return []string{"You must not set an initial state as well as regions."}
+	}
+
+	// regionOf maps each state to the first region found that can reach it.
+	regionOf := map[string]string{}
+	for _, region := range sm.regions {
+		if region.name == "" || strings.ContainsAny(region.name, ",:") {
+			problems = append(problems, fmt.Sprintf("%q is not a valid region name.", region.name))
+		}
+
+		if _, ok := sm.states[region.initial]; !ok {
+			problems = append(problems, fmt.Sprintf("The initial state of the %s region was not found in the registered states.", region.name))
+			continue
+		}
+		if !checkReachable {
+			continue
+		}
+
+		for _, state := range sortedKeys(sm.reachableFrom(region.initial)) {
+			if other, ok := regionOf[state]; ok && other != region.name {
+				problems = append(problems, fmt.Sprintf("%s cannot be in both the %s & %s regions.", state, other, region.name))
+				continue
+			}
+			regionOf[state] = region.name
+		}
+	}
+
+	names := map[string]struct{}{}
+	for _, region := range sm.regions {
+		if _, ok := names[region.name]; ok {
+			problems = append(problems, fmt.Sprintf("Regions must have unique names: got %s more than once.", region.name))
+		}
+		names[region.name] = struct{}{}
+	}
+
+	return problems
+}
@@ -0,0 +1,145 @@
+package state52_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+var shipmentEvents = state52.Events{
+	{
+		Name: "pay",
+		Transitions: state52.Transitions{
+			{From: []string{"unpaid"}, To: "paid"},
+		},
+	},
+	{
+		Name: "pack",
+		Transitions: state52.Transitions{
+			{From: []string{"pending"}, To: "packed"},
+		},
+	},
+	{
+		Name: "ship",
+		Transitions: state52.Transitions{
+			{From: []string{"packed"}, To: "shipped"},
+		},
+	},
+	{
+		Name: "cancel",
+		Transitions: state52.Transitions{
+			{From: []string{"unpaid"}, To: "voided"},
+			{From: []string{"pending", "packed"}, To: "cancelled"},
+		},
+	},
+}
+
+func TestRegions(t *testing.T) {
+	persisted := []string{}
+	sm, err := state52.New(
+		state52.SetRegion("payment", "unpaid"),
+		state52.SetRegion("fulfillment", "pending"),
+		state52.SetEvents(shipmentEvents),
+		state52.SetFinalStates("paid", "shipped"),
+		state52.SetPersistFn(func(state string) error {
+			persisted = append(persisted, state)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "payment:unpaid,fulfillment:pending" {
+		t.Errorf("expected state to be 'payment:unpaid,fulfillment:pending', got %s", sm.CurrentState())
+	}
+
+	for _, event := range []string{"pack", "pay", "ship"} {
+		err := sm.Event(event)
+		if err != nil {
+			t.Errorf("expected error message to be: nil, got %s", err.Error())
+		}
+	}
+
+	expectedPersisted := []string{
+		"payment:unpaid,fulfillment:packed",
+		"payment:paid,fulfillment:packed",
+		"payment:paid,fulfillment:shipped",
+	}
+	if fmt.Sprint(persisted) != fmt.Sprint(expectedPersisted) {
+		t.Errorf("expected the persisted states to be %v, got %v", expectedPersisted, persisted)
+	}
+
+	expectedConfiguration := map[string]string{"payment": "paid", "fulfillment": "shipped"}
+	if fmt.Sprint(sm.Configuration()) != fmt.Sprint(expectedConfiguration) {
+		t.Errorf("expected configuration to be %v, got %v", expectedConfiguration, sm.Configuration())
+	}
+
+	select {
+	case <-sm.Done():
+	default:
+		t.Errorf("expected Done() to be closed once every region is in a final state")
+	}
+}
+
+func TestEventInEveryRegion(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetRegion("payment", "unpaid"),
+		state52.SetRegion("fulfillment", "pending"),
+		state52.SetEvents(shipmentEvents),
+		state52.SetCurrentState("payment:unpaid,fulfillment:packed"),
+	)
+
+	err := sm.Event("cancel")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "payment:voided,fulfillment:cancelled" {
+		t.Errorf("expected state to be 'payment:voided,fulfillment:cancelled', got %s", sm.CurrentState())
+	}
+
+	err = sm.Event("pay")
+	expectedError := "Cannot transition from payment:voided,fulfillment:cancelled when calling pay."
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("expected error message to be: %s, got %v", expectedError, err)
+	}
+}
+
+func TestInvalidRegions(t *testing.T) {
+	_, err := state52.New(
+		state52.SetRegion("payment", "unpaid"),
+		state52.SetRegion("fulfillment", "pending"),
+		state52.SetEvents(shipmentEvents),
+		state52.SetInitial("unpaid"),
+		state52.SetRegion("returns", "packed"),
+		state52.SetCurrentState("payment:pending,fulfillment:packed"),
+	)
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{
+		"You must not set an initial state as well as regions.",
+		"payment:pending,fulfillment:packed has no state for the returns region.",
+		"pending is not in the payment region.",
+	}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+
+	_, err = state52.New(
+		state52.SetRegion("payment", "unpaid"),
+		state52.SetRegion("fulfillment", "pending"),
+		state52.SetEvents(shipmentEvents),
+		state52.SetRegion("returns", "packed"),
+	)
+	expectedError := "Invalid state machine: cancelled cannot be in both the fulfillment & returns regions. packed cannot be in both the fulfillment & returns regions. shipped cannot be in both the fulfillment & returns regions."
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("expected error message to be: %s, got %v", expectedError, err)
+	}
+}
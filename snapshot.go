@@ -0,0 +1,61 @@
+package state52
+
+import "context"
+
+// Snapshot is everything needed to restore a state machine to where it was.
+//
+// The data carried by the state machine is unexported, as it is not of a
+// known type: a persistFn set with SetPersistSnapshotFn cannot read it, and
+// encoding a Snapshot (e.g. as JSON) drops it. To persist & restore the data
+// too, use the TypedSnapshot of Data.PersistSnapshotFn & Data.Restore.
+type Snapshot struct {
+	// State is the current state, as returned by CurrentState.
+	State string `json:"state" yaml:"state"`
+
+	// LastActive maps each composite state that has been exited to the
+	// substate that was last active within it, as resumed by transitions
+	// with ShallowHistory or DeepHistory.
+	LastActive map[string]string `json:"last_active,omitempty" yaml:"last_active,omitempty"`
+
+	// data is the state machine's data, which a TypedSnapshot makes available.
+	data interface{}
+}
+
+// Snapshot returns a Snapshot of the state machine.
+func (sm *State52) Snapshot() Snapshot {
+	sm.stateMutex.RLock()
+	defer sm.stateMutex.RUnlock()
+	return Snapshot{State: sm.configuration(sm.currentStates), LastActive: copyLastActive(sm.lastActive), data: sm.data}
+}
+
+// SetPersistSnapshotFn sets a persistFn that receives the Snapshot the
+// state machine will be in once the transition has been performed.
+func SetPersistSnapshotFn(fn func(context.Context, Snapshot) error) SetupFunc {
+	return func(sm *State52) error {
+		sm.persistFn = fn
+		sm.persistsState = ""
+		return nil
+	}
+}
+
+// SetSnapshot restores the state machine to a previously persisted Snapshot.
+func SetSnapshot(snapshot Snapshot) SetupFunc {
+	return func(sm *State52) error {
+		sm.restored = snapshot
+		if sm.restoresState == "SetCurrentState" {
+			sm.restoresState = ""
+		}
+		return nil
+	}
+}
+
+// SetLoadSnapshotFn sets a loadFn that returns a previously persisted
+// Snapshot, restored as with SetSnapshot. Returning a Snapshot
+// without a State starts the machine in its initialState.
+func SetLoadSnapshotFn(fn func() (Snapshot, error)) SetupFunc {
+	return func(sm *State52) error {
+		sm.loadFn = fn
+		sm.restoresState = ""
+		return nil
+	}
+}
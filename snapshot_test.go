@@ -0,0 +1,97 @@
+package state52_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+func TestPersistSnapshot(t *testing.T) {
+	snapshots := []state52.Snapshot{}
+	sm := state52.MustNew(
+		state52.SetInitial("draft"),
+		state52.SetSubstates("running", "drafting", "reviewing"),
+		state52.SetSubstates("reviewing", "first_review", "second_review"),
+		state52.SetEvents(workflowEvents),
+		state52.SetPersistSnapshotFn(func(ctx context.Context, snapshot state52.Snapshot) error {
+			snapshots = append(snapshots, snapshot)
+			return nil
+		}),
+	)
+
+	for _, event := range []string{"start", "review", "review", "pause"} {
+		sm.Event(event)
+	}
+
+	expected := state52.Snapshot{
+		State:      "paused",
+		LastActive: map[string]string{"running": "reviewing", "reviewing": "second_review"},
+	}
+	if fmt.Sprint(snapshots[len(snapshots)-1]) != fmt.Sprint(expected) {
+		t.Errorf("expected the persisted snapshot to be %v, got %v", expected, snapshots[len(snapshots)-1])
+	}
+
+	if fmt.Sprint(sm.Snapshot()) != fmt.Sprint(expected) {
+		t.Errorf("expected the snapshot to be %v, got %v", expected, sm.Snapshot())
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	snapshot := state52.Snapshot{
+		State:      "paused",
+		LastActive: map[string]string{"running": "reviewing", "reviewing": "second_review"},
+	}
+
+	sm := state52.MustNew(
+		state52.SetInitial("draft"),
+		state52.SetSubstates("running", "drafting", "reviewing"),
+		state52.SetSubstates("reviewing", "first_review", "second_review"),
+		state52.SetEvents(workflowEvents),
+		state52.SetLoadSnapshotFn(func() (state52.Snapshot, error) {
+			return snapshot, nil
+		}),
+	)
+
+	if sm.CurrentState() != "paused" {
+		t.Errorf("expected state to be 'paused', got %s", sm.CurrentState())
+	}
+
+	sm.Event("resume_deep")
+	if sm.CurrentStatePath() != "running.reviewing.second_review" {
+		t.Errorf("expected state to be 'running.reviewing.second_review', got %s", sm.CurrentStatePath())
+	}
+}
+
+func TestRestoreInvalidSnapshot(t *testing.T) {
+	_, err := state52.New(
+		state52.SetInitial("start"),
+		state52.SetSubstates("running", "drafting"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "running"},
+					},
+				},
+			},
+		),
+		state52.SetSnapshot(state52.Snapshot{
+			State:      "start",
+			LastActive: map[string]string{"running": "start"},
+		}),
+	)
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{"start is not a substate of running."}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+}
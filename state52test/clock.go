@@ -0,0 +1,87 @@
+// Package state52test provides utilities for testing state machines.
+package state52test
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benhawker/state52"
+)
+
+// FakeClock is a state52.Clock whose time only moves when Advance is called.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a Timer started by a FakeClock.
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	f     func()
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the FakeClock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// AfterFunc calls f once the FakeClock has been advanced by d.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) state52.Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &fakeTimer{c, c.now.Add(d), f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the FakeClock forward by d, calling the fn of each timer
+// that becomes due, in the order they are due, before it returns.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	end := c.now.Add(d)
+
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].when.Before(c.timers[j].when)
+		})
+		if len(c.timers) == 0 || c.timers[0].when.After(end) {
+			break
+		}
+
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.when
+
+		// Timers may start further timers, so the mutex is not held.
+		c.mutex.Unlock()
+		t.f()
+		c.mutex.Lock()
+	}
+
+	c.now = end
+	c.mutex.Unlock()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -1,17 +1,32 @@
 package state52
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 var validglobalCallbacks = []string{"before_all_events", "after_all_events", "ensure_all_events"}
 var validEventCallbacks = []string{"before", "after", "ensure"}
 var validTransitionCallbacks = []string{"after", "success"}
+var validStateCallbacks = []string{"on_enter", "on_exit"}
 
 // State52 defines your Finite State Machine.
 type State52 struct {
+	*machine
+
+	// nested is set on the State52 passed to callbacks while an event is
+	// being fired. Events fired through it run straight away, without taking
+	// the eventLock, as the firing event already holds it.
+	nested bool
+}
+
+// machine holds the definition & state shared by every State52 handle.
+type machine struct {
 	// initialState is the initial state.
 	initialState string
 
@@ -21,18 +36,115 @@ type State52 struct {
 	// globalCallbacks defines callbacks that will called for all Events.
 	globalCallbacks map[string]callback
 
+	// globalHooks defines further callbacks that will be called for all Events.
+	globalHooks Hooks
+
+	// globalChains holds the globalCallbacks & globalHooks, in the order they are called.
+	globalChains map[string][]Hook
+
 	// persistFn defines a fn that will be called at the
 	// appropriate moment in each event to persist the state.
-	persistFn func(string) error
+	persistFn func(context.Context, Snapshot) error
+
+	// persistsState & restoresState name the option, if any, that persists
+	// or restores only the state, losing the lastActive substates.
+	persistsState string
+	restoresState string
+
+	// regions are the orthogonal regions of the state machine.
+	regions []region
+
+	// currentStates represents the current state of each region.
+	currentStates []string
+
+	// lastActive maps each composite state that has been exited
+	// to the substate that was last active within it.
+	lastActive map[string]string
+
+	// data is the extended state carried by the state machine.
+	data interface{}
 
-	// currentState represents the current state.
-	currentState string
+	// stateValues & eventValues map the names of the states & events
+	// of a Machine to their values, see Machine.
+	stateValues map[string]interface{}
+	eventValues map[string]interface{}
 
-	// stateMutex locks/unlocks access to the current state.
+	// stateMutex locks/unlocks access to the current state, lastActive & data.
 	stateMutex sync.RWMutex
 
 	// states holds a map of all possible states
 	states map[string]struct{}
+
+	// parents maps each substate to the composite state containing it.
+	parents map[string]string
+
+	// substates maps each composite state to its substates,
+	// the first of which is its initial substate.
+	substates map[string][]string
+
+	// restored is a previously persisted Snapshot the
+	// state machine should start in instead of initialState.
+	restored Snapshot
+
+	// loadFn defines a fn that will be called when the state machine
+	// is created to load a previously persisted Snapshot.
+	loadFn func() (Snapshot, error)
+
+	// clock tells the time & starts the timers of timed transitions.
+	clock Clock
+
+	// timed holds the timed transitions waiting on their timers, keyed by
+	// the state that started them. Guarded by eventLock.
+	timed   map[string][]*timedTransition
+	stopped bool
+
+	// eventLock ensures events are fired one at a time.
+	eventLock eventLock
+
+	// queue holds events raised by callbacks, to be fired once
+	// the event being fired has completed. Guarded by eventLock.
+	queue []queuedEvent
+
+	// finalStates holds the states in which the state machine is complete.
+	finalStates map[string]struct{}
+
+	// done is closed when the state machine enters a final state.
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// history holds the last historyLimit events fired.
+	history      []Record
+	historyLimit int
+	historyMutex sync.RWMutex
+
+	// historySink receives a Record for every event fired.
+	historySink HistorySink
+
+	// stateCallbacks & stateHooks define the callbacks called when
+	// entering & exiting each state.
+	stateCallbacks map[string]StateCallbacks
+	stateHooks     map[string]StateHooks
+
+	// globalStateCallbacks & globalStateHooks define the callbacks
+	// called when entering & exiting every state.
+	globalStateCallbacks StateCallbacks
+	globalStateHooks     StateHooks
+
+	// stateChains & globalStateChains hold the State callbacks
+	// & hooks, in the order they are called.
+	stateChains       map[string]map[string][]StateHook
+	globalStateChains map[string][]StateHook
+
+	// observers are notified as events are fired. Each is held by
+	// a pointer of its own, so that AddObserver can remove it again.
+	observers      []*Observer
+	observersMutex sync.RWMutex
+}
+
+// queuedEvent is an event raised by a callback via Raise.
+type queuedEvent struct {
+	name string
+	args []interface{}
 }
 
 // Event provides the format for defining an event when creating a State Machine.
@@ -47,15 +159,32 @@ type Event struct {
 	// guard is a function that returns a bool, if you want to provide a
 	// guard for all transitions within an event. A guard is a condition
 	// that must be met for the event transition's to execute.
-	Guards []func() bool
+	Guards []Guard
 
 	// Callbacks is a map of transition `Callback`(s) specifically
 	// to be run for an event. The code refers to these as Event Callbacks.
 	// callbacks map[string]callback
 	Callbacks map[string]callback
 
-	// err is an optional error that can be returned from a callback.
+	// Hooks allows several named callbacks, ordered by priority, to be
+	// run for the same Event callback. They run alongside Callbacks.
+	Hooks Hooks
+
+	// chains holds the Callbacks & Hooks, in the order they are called.
+	chains map[string][]Hook
+
+	// err is the error the event failed with, made available
+	// to ensure callbacks via Err().
 	err error
+
+	// ctx is the context the event is being fired with.
+	ctx context.Context
+
+	// args are the arguments the event is being fired with.
+	args []interface{}
+
+	// machine is the state machine the event is being fired on.
+	machine *machine
 }
 
 // Transition defines a transition that can be made (within an event).
@@ -70,11 +199,27 @@ type Transition struct {
 	// guard is a function that returns a bool, if you want to provide
 	// a guard for this specific transition. A guard is a condition
 	// that must be met for the transition to execute.
-	Guards []func() bool
+	Guards []Guard
 
 	// callbacks is a map of transition `Callback`(s) specifically run for this
 	// specific transition. The code refers to these as Transition Callbacks.
 	Callbacks map[string]tCallback
+
+	// After makes the transition timed: it is performed automatically once
+	// the state machine has been in a From state for After, unless the state
+	// is exited first. A timed transition is not performed by calling Event.
+	After time.Duration
+
+	// History controls which substate is entered if To is a composite
+	// state, i.e. whether the substate last active within it is resumed.
+	History HistoryMode
+
+	// Hooks allows several named callbacks, ordered by priority, to be
+	// run for the same Transition callback. They run alongside Callbacks.
+	Hooks TransitionHooks
+
+	// chains holds the Callbacks & Hooks, in the order they are called.
+	chains map[string][]TransitionHook
 }
 
 // Events -> Syntax for building the state machine
@@ -87,7 +232,8 @@ type Transitions []Transition
 type Callbacks map[string]callback
 
 // callback is a function type that all Global and Event callbacks should use.
-// Event is the current event data passed as the callback happens.
+// Event is the current event data passed as the callback happens,
+// including the Args() it was fired with.
 type callback func(*State52, *Event) error
 
 // TransitionCallbacks -> Syntax for building the state machine
@@ -97,14 +243,20 @@ type TransitionCallbacks map[string]tCallback
 // This allows us access to Transition & associated Event data.
 type tCallback func(*State52, *Event, *Transition) error
 
+// StateCallbacks -> Syntax for building the state machine
+type StateCallbacks map[string]sCallback
+
+// sCallback is a function type that all State callbacks should use.
+// state is the state being entered or exited.
+type sCallback func(*State52, *Event, string) error
+
 // Guards -> Syntax for building the state machine
-type Guards []func() bool
+type Guards []Guard
 
 // SetInitial sets the initialState.
 func SetInitial(state string) SetupFunc {
 	return func(sm *State52) error {
 		sm.initialState = state
-		sm.currentState = state
 		return nil
 	}
 }
@@ -112,7 +264,99 @@ func SetInitial(state string) SetupFunc {
 // SetPersistFn sets the persistFn.
 func SetPersistFn(fn func(string) error) SetupFunc {
 	return func(c *State52) error {
-		c.persistFn = fn
+		c.persistFn = func(_ context.Context, snapshot Snapshot) error {
+			return fn(snapshot.State)
+		}
+		c.persistsState = "SetPersistFn"
+		return nil
+	}
+}
+
+// SetPersistFnContext sets a persistFn that receives the context
+// the event was fired with.
+func SetPersistFnContext(fn func(context.Context, string) error) SetupFunc {
+	return func(c *State52) error {
+		c.persistFn = func(ctx context.Context, snapshot Snapshot) error {
+			return fn(ctx, snapshot.State)
+		}
+		c.persistsState = "SetPersistFnContext"
+		return nil
+	}
+}
+
+// SetCurrentState restores the state machine to a previously persisted state.
+// The state must be one of the registered states.
+func SetCurrentState(state string) SetupFunc {
+	return func(sm *State52) error {
+		sm.restored = Snapshot{State: state}
+		sm.restoresState = "SetCurrentState"
+		return nil
+	}
+}
+
+// SetLoadFn sets the loadFn, the counterpart to the persistFn. It is called
+// once when the state machine is created & the state it returns is restored
+// as with SetCurrentState. Returning "" starts the machine in its initialState.
+func SetLoadFn(fn func() (string, error)) SetupFunc {
+	return func(sm *State52) error {
+		sm.loadFn = func() (Snapshot, error) {
+			state, err := fn()
+			return Snapshot{State: state}, err
+		}
+		sm.restoresState = "SetLoadFn"
+		return nil
+	}
+}
+
+// SetFinalStates sets the states in which the state machine is complete.
+// Once a final state is entered no further events can be fired.
+func SetFinalStates(states ...string) SetupFunc {
+	return func(sm *State52) error {
+		sm.finalStates = map[string]struct{}{}
+		for _, state := range states {
+			sm.finalStates[state] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// SetStateCallbacks sets the on_enter & on_exit callbacks for state.
+func SetStateCallbacks(state string, callbacks StateCallbacks) SetupFunc {
+	return func(sm *State52) error {
+		if sm.stateCallbacks == nil {
+			sm.stateCallbacks = map[string]StateCallbacks{}
+		}
+		sm.stateCallbacks[state] = callbacks
+		return nil
+	}
+}
+
+// SetStateHooks sets on_enter & on_exit hooks for state.
+// They are run alongside any set with SetStateCallbacks.
+func SetStateHooks(state string, hooks StateHooks) SetupFunc {
+	return func(sm *State52) error {
+		if sm.stateHooks == nil {
+			sm.stateHooks = map[string]StateHooks{}
+		}
+		sm.stateHooks[state] = hooks
+		return nil
+	}
+}
+
+// SetGlobalStateCallbacks sets on_enter & on_exit callbacks that will be
+// called for every state, after the state's own callbacks.
+func SetGlobalStateCallbacks(callbacks StateCallbacks) SetupFunc {
+	return func(sm *State52) error {
+		sm.globalStateCallbacks = callbacks
+		return nil
+	}
+}
+
+// SetGlobalStateHooks sets on_enter & on_exit hooks for every state.
+// They are run alongside any set with SetGlobalStateCallbacks.
+func SetGlobalStateHooks(hooks StateHooks) SetupFunc {
+	return func(sm *State52) error {
+		sm.globalStateHooks = hooks
 		return nil
 	}
 }
@@ -136,28 +380,116 @@ func SetEvents(events Events) SetupFunc {
 // SetupFunc is a function that configures a State52 (state machine).
 type SetupFunc func(*State52) error
 
-// NewStateMachine allows initialisation of a StateMachine.
-func NewStateMachine(options ...SetupFunc) *State52 {
-	sm := &State52{}
+// New allows initialisation of a StateMachine. If the options
+// are invalid a ValidationError listing every problem is returned.
+func New(options ...SetupFunc) (*State52, error) {
+	sm := &State52{machine: &machine{done: make(chan struct{}), eventLock: newEventLock()}}
+	problems := []string{}
 
 	// Apply passed options.
 	for _, option := range options {
-		if err := option(sm); err != nil {
-			panic(err)
+		err := option(sm)
+		var validationErr ValidationError
+		if errors.As(err, &validationErr) {
+			problems = append(problems, validationErr.Problems...)
+		} else if err != nil {
+			problems = append(problems, err.Error())
 		}
 	}
 
 	// Always build states
 	sm.states = mapStates(sm.events)
+	for parent, substates := range sm.substates {
+		sm.states[parent] = struct{}{}
+		for _, substate := range substates {
+			sm.states[substate] = struct{}{}
+		}
+	}
+	sm.globalChains = chainHooks(sm.globalCallbacks, sm.globalHooks)
+	sm.globalStateChains = chainHooks(sm.globalStateCallbacks, sm.globalStateHooks)
+	withCallbacks := map[string]struct{}{}
+	for state := range sm.stateCallbacks {
+		withCallbacks[state] = struct{}{}
+	}
+	for state := range sm.stateHooks {
+		withCallbacks[state] = struct{}{}
+	}
+	sm.stateChains = map[string]map[string][]StateHook{}
+	for state := range withCallbacks {
+		sm.stateChains[state] = chainHooks(sm.stateCallbacks[state], sm.stateHooks[state])
+	}
+
+	// A state machine without regions has a single region.
+	setRegions := sm.regions
+	if len(sm.regions) == 0 {
+		sm.regions = []region{{"", sm.initialState}}
+	}
+
+	// Load any persisted state.
+	if sm.loadFn != nil {
+		snapshot, err := sm.loadFn()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Loading the persisted state failed: %s.", err))
+		} else if snapshot.State != "" {
+			sm.restored = snapshot
+		}
+	}
 
-	sm.validate()
+	problems = append(problems, sm.validate(setRegions)...)
+	if len(problems) > 0 {
+		return nil, ValidationError{problems}
+	}
+
+	for _, region := range sm.regions {
+		sm.currentStates = append(sm.currentStates, region.initial)
+	}
+	if sm.restored.State != "" {
+		sm.currentStates, _ = sm.parseConfiguration(sm.restored.State)
+		sm.lastActive = copyLastActive(sm.restored.LastActive)
+		if sm.restored.data != nil {
+			sm.data = sm.restored.data
+		}
+	}
+	for i, state := range sm.currentStates {
+		sm.currentStates[i] = sm.resolveTarget(state)
+	}
+
+	if sm.clock == nil {
+		sm.clock = realClock{}
+	}
+	sm.timed = map[string][]*timedTransition{}
+	sm.eventLock.lock()
+	for _, state := range sm.currentStates {
+		sm.startTimers(sm.path(state))
+	}
+	sm.eventLock.unlock()
+	sm.checkDone()
+	return sm, nil
+}
+
+// MustNew is like New but panics if the options are invalid.
+func MustNew(options ...SetupFunc) *State52 {
+	sm, err := New(options...)
+	if err != nil {
+		panic(err)
+	}
 	return sm
 }
 
+// NewStateMachine allows initialisation of a StateMachine.
+// It panics if the options are invalid.
+//
+// Deprecated: Use New, or MustNew.
+func NewStateMachine(options ...SetupFunc) *State52 {
+	return MustNew(options...)
+}
+
 func mapEvents(events []Event) map[string]Event {
 	mapppedEvents := map[string]Event{}
 
 	for _, e := range events {
+		e.chains = chainHooks(e.Callbacks, e.Hooks)
+
 		if len(e.Guards) > 0 {
 			// An event level guard can specify a single guard to be applied to all transitions within an event.
 			// So we append event guards to every transition within the event.
@@ -170,6 +502,13 @@ func mapEvents(events []Event) map[string]Event {
 			e.Transitions = newTransitions
 		}
 
+		newTransitions := []Transition{}
+		for _, transition := range e.Transitions {
+			transition.chains = chainHooks(transition.Callbacks, transition.Hooks)
+			newTransitions = append(newTransitions, transition)
+		}
+		e.Transitions = newTransitions
+
 		mapppedEvents[e.Name] = e
 	}
 
@@ -192,59 +531,153 @@ func mapStates(events map[string]Event) map[string]struct{} {
 	return allRegisteredStates
 }
 
-func (sm *State52) validate() {
-	// Validate presence of initialState
-	if sm.initialState == "" {
-		panic("You must set an initial state.")
+// validate returns a description of every problem found with the state machine.
+func (sm *State52) validate(regions []region) []string {
+	problems := []string{}
+
+	// Which states are reachable is only known without cycles of composite states.
+	substateProblems := sm.validateSubstates()
+	checkReachable := len(substateProblems) == 0
+
+	// Validate presence of initialState, or of regions.
+	if len(regions) > 0 {
+		problems = append(problems, sm.validateRegions(checkReachable)...)
+	} else if sm.initialState == "" {
+		problems = append(problems, "You must set an initial state.")
+	} else if _, ok := sm.states[sm.initialState]; !ok {
+		// Validate the initial state is included in at least one event transition to/from.
+		// Note that this checks both to & from attributes whereas it would require being present in `to` in reality.
+		problems = append(problems, "initialState was not found in the registered states.")
 	}
 
-	// Validate the initial state is included in at least one event transition to/from.
-	// Note that this checks both to & from attributes whereas it would require being present in `to` in reality.
-	if _, ok := sm.states[sm.initialState]; !ok {
-		panic("initialState was not found in the registered states.")
+	// Validate any restored state is a registered state.
+	if sm.restored.State != "" {
+		states, configurationProblems := sm.parseConfiguration(sm.restored.State)
+		problems = append(problems, configurationProblems...)
+		for i, state := range states {
+			if state == "" {
+				continue
+			}
+			if _, ok := sm.states[state]; !ok {
+				problems = append(problems, fmt.Sprintf("%s was not found in the registered states.", state))
+			} else if len(regions) == 0 || !checkReachable {
+				continue
+			} else if _, ok := sm.reachableFrom(sm.regions[i].initial)[state]; !ok {
+				problems = append(problems, fmt.Sprintf("%s is not in the %s region.", state, sm.regions[i].name))
+			}
+		}
+		problems = append(problems, sm.validateLastActive(sm.restored.LastActive)...)
+	}
+
+	// Validate final states are registered states.
+	for _, state := range sortedKeys(sm.finalStates) {
+		if _, ok := sm.states[state]; !ok {
+			problems = append(problems, fmt.Sprintf("Final state %s was not found in the registered states.", state))
+		}
+	}
+
+	// Validate the history limit.
+	if sm.historyLimit < 0 {
+		problems = append(problems, "The history limit must not be negative.")
 	}
 
 	// Validate at least 1 event
 	if len(sm.events) == 0 {
-		panic("You must define at least 1 event.")
+		problems = append(problems, "You must define at least 1 event.")
 	}
 
 	// Validate globalCallbacks
-	for name := range sm.globalCallbacks {
-		if !stringInSlice(name, validglobalCallbacks) {
-			panic(fmt.Sprintf("%s is not a valid Global Callback. The following are valid: %s.", name, strings.Join(validglobalCallbacks, ",")))
+	problems = append(problems, validateHooks(sm.globalChains, validglobalCallbacks, "Global Callback")...)
+
+	// Validate composite states
+	problems = append(problems, substateProblems...)
+	problems = append(problems, sm.validateHistory()...)
+
+	// Validate State Callbacks
+	problems = append(problems, validateHooks(sm.globalStateChains, validStateCallbacks, "State Callback")...)
+	for _, state := range sortedKeys(sm.stateChains) {
+		if _, ok := sm.states[state]; !ok {
+			problems = append(problems, fmt.Sprintf("State callbacks are set for %s, which was not found in the registered states.", state))
 		}
+		problems = append(problems, validateHooks(sm.stateChains[state], validStateCallbacks, "State Callback")...)
 	}
 
 	// Validate Event & Transition Callbacks
-	for _, event := range sm.events {
-		event.validate()
+	for _, name := range sortedKeys(sm.events) {
+		event := sm.events[name]
+		problems = append(problems, event.validate()...)
 	}
+
+	return problems
 }
 
-func (event *Event) validate() {
+// validate returns a description of every problem found with the event.
+func (event *Event) validate() []string {
+	problems := []string{}
+
 	// Validates all event level callbacks.
-	for callbackName := range event.Callbacks {
-		if !stringInSlice(callbackName, validEventCallbacks) {
-			panic(fmt.Sprintf("%s is not a valid Event Callback. The following are valid: %s.", callbackName, strings.Join(validEventCallbacks, ",")))
-		}
-	}
+	problems = append(problems, validateHooks(event.chains, validEventCallbacks, "Event Callback")...)
 
 	// Validates all transition level callbacks.
 	for _, transition := range event.Transitions {
-		for callbackName := range transition.Callbacks {
-			if !stringInSlice(callbackName, validTransitionCallbacks) {
-				panic(fmt.Sprintf("%s is not a valid Transition Callback. The following are valid: %s.", callbackName, strings.Join(validTransitionCallbacks, ",")))
-			}
+		if transition.After < 0 {
+			problems = append(problems, fmt.Sprintf("%s has a transition with a negative After.", event.Name))
 		}
+		problems = append(problems, validateHooks(transition.chains, validTransitionCallbacks, "Transition Callback")...)
 	}
+
+	return problems
+}
+
+// ValidationError will be returned by New() when the
+// state machine is invalid. It lists every problem found.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("Invalid state machine: %s", strings.Join(e.Problems, " "))
 }
 
-// CurrentState returns the current state of the sm.
+// CurrentState returns the current state of the sm. For a state machine
+// with regions it is the state of each region, e.g. "payment:paid,fulfillment:packed".
 func (sm *State52) CurrentState() string {
-	sm.stateMutex.RLock()
-	defer sm.stateMutex.RUnlock()
-	return sm.currentState
+	return sm.configuration(sm.regionStates())
+}
+
+// IsFinal reports whether the state machine is in a final state, or in a
+// substate of one. A state machine with regions is in a final state once every region is.
+func (sm *State52) IsFinal() bool {
+	for _, state := range sm.regionStates() {
+		if !sm.isFinal(state) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFinal reports whether state is a final state, or a substate of one.
+func (sm *State52) isFinal(state string) bool {
+	for final := range sm.finalStates {
+		if sm.isIn(state, final) {
+			return true
+		}
+	}
+	return false
+}
+
+// Done returns a channel that is closed when the state machine enters a final state.
+func (sm *State52) Done() <-chan struct{} {
+	return sm.done
+}
+
+// checkDone closes the done channel if the state machine is in a final state.
+func (sm *State52) checkDone() {
+	if sm.IsFinal() {
+		sm.doneOnce.Do(func() {
+			close(sm.done)
+		})
+	}
 }
 
 func stringInSlice(a string, list []string) bool {
@@ -255,3 +688,13 @@ func stringInSlice(a string, list []string) bool {
 	}
 	return false
 }
+
+// sortedKeys returns the keys of m in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
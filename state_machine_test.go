@@ -1,9 +1,12 @@
 package state52_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/benhawker/state52"
 )
@@ -55,6 +58,89 @@ func TestNoEvents(t *testing.T) {
 	)
 }
 
+func TestNewReturnsValidationError(t *testing.T) {
+	sm, err := state52.New(
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+					Callbacks: state52.Callbacks{
+						"not_an_event_callback": func(sm *state52.State52, e *state52.Event) error {
+							return nil
+						},
+					},
+				},
+			},
+		),
+		state52.SetGlobalCallbacks(
+			state52.Callbacks{
+				"not_a_global_callback": func(sm *state52.State52, e *state52.Event) error {
+					return nil
+				},
+			},
+		),
+		func(sm *state52.State52) error {
+			return errors.New("option failed")
+		},
+	)
+
+	if sm != nil {
+		t.Errorf("expected no state machine to be returned")
+	}
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expectedProblems := []string{
+		"option failed",
+		"You must set an initial state.",
+		"not_a_global_callback is not a valid Global Callback. The following are valid: before_all_events,after_all_events,ensure_all_events.",
+		"not_an_event_callback is not a valid Event Callback. The following are valid: before,after,ensure.",
+	}
+	if fmt.Sprint(validationErr.Problems) != fmt.Sprint(expectedProblems) {
+		t.Errorf("expected problems to be: %q, got %q", expectedProblems, validationErr.Problems)
+	}
+}
+
+func TestNewValid(t *testing.T) {
+	sm, err := state52.New(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+			},
+		),
+	)
+	if err != nil {
+		t.Fatalf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
+	}
+}
+
+func TestMustNewPanics(t *testing.T) {
+	defer func() {
+		r := recover()
+		if _, ok := r.(state52.ValidationError); !ok {
+			t.Errorf("Expected panic with a ValidationError, got: %v", r)
+		}
+	}()
+
+	state52.MustNew()
+}
+
 func TestInvalidGlobalCallback(t *testing.T) {
 	events := state52.Events{
 		{
@@ -473,39 +559,992 @@ func TestCallingEventFnWithinCallback(t *testing.T) {
 	}
 }
 
-func TestEventNotRegisteredError(t *testing.T) {
-	eventName := "not_an_event"
+func TestCallingCanOnOuterStateMachineWithinGuard(t *testing.T) {
+	var sm *state52.State52
+	var could bool
 
-	e := state52.EventNotRegisteredError{eventName}
-	if e.Error() != fmt.Sprintf("%s is not registered.", e.EventName) {
-		t.Errorf("Expected %s, Got: %s", fmt.Sprintf("%s is not registered.", e.EventName), e.Error())
+	sm = state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first",
+							Guards: state52.Guards{state52.Condition(func() bool {
+								could = sm.Can("second_event")
+								return true
+							})},
+						},
+					},
+				},
+				{
+					Name: "second_event",
+					Transitions: state52.Transitions{
+						{From: []string{"succeeded_first"}, To: "succeeded_second"},
+					},
+				},
+			},
+		),
+	)
+
+	if err := sm.Event("first_event"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if could {
+		t.Errorf("expected second_event not to be possible from start")
+	}
+
+	if sm.CurrentState() != "succeeded_first" {
+		t.Errorf("expected state to be 'succeeded_first', got %s", sm.CurrentState())
 	}
 }
 
-func TestCannotTransitionError(t *testing.T) {
-	eventName := "not_an_event"
-	currentState := "initial"
+func TestCallingNestedStateMachineFromGoroutineSkipsLock(t *testing.T) {
+	var secondErr error
 
-	e := state52.CannotTransitionError{currentState, eventName}
-	if e.Error() != fmt.Sprintf("Cannot transition from %s when calling %s.", e.CurrentState, e.EventName) {
-		t.Errorf("Expected %s, Got: %s", fmt.Sprintf("Cannot transition from %s when calling %s.", e.CurrentState, e.EventName), e.Error())
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+					Callbacks: state52.Callbacks{
+						"after": func(sm *state52.State52, e *state52.Event) error {
+							// The State52 passed to the callback does not take the event
+							// lock, even from another goroutine, so second_event is fired
+							// while first_event is still being fired rather than after it.
+							done := make(chan struct{})
+							go func() {
+								secondErr = sm.Event("second_event")
+								close(done)
+							}()
+							<-done
+							return nil
+						},
+					},
+				},
+				{
+					Name: "second_event",
+					Transitions: state52.Transitions{
+						{From: []string{"succeeded_first"}, To: "succeeded_second"},
+					},
+				},
+			},
+		),
+	)
+
+	if err := sm.Event("first_event"); err != nil || secondErr != nil {
+		t.Errorf("expected no errors, got %v & %v", err, secondErr)
+	}
+
+	if sm.CurrentState() != "succeeded_second" {
+		t.Errorf("expected state to be 'succeeded_second', got %s", sm.CurrentState())
 	}
 }
 
-func TestPersistFailedError(t *testing.T) {
-	eventName := "event"
-	message := errors.New("something broke")
+func TestEventContextCanceled(t *testing.T) {
+	persisted := false
 
-	e := state52.PersistFailedError{message, eventName}
-	if e.Error() != fmt.Sprintf("Perist failed for %s: %s", e.EventName, e.Message) {
-		t.Errorf("Expected %s, Got: %s", fmt.Sprintf("Perist failed for %s: %s", e.EventName, e.Message), e.Error())
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+			},
+		),
+		state52.SetPersistFn(func(newState string) error {
+			persisted = true
+			return nil
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := sm.EventContext(ctx, "first_event")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected error to wrap context.Canceled, got %v", err)
+	}
+
+	var canceledErr state52.EventCanceledError
+	if !errors.As(err, &canceledErr) || canceledErr.EventName != "first_event" {
+		t.Errorf("expected an EventCanceledError for first_event, got %v", err)
+	}
+
+	if persisted {
+		t.Errorf("expected persistFn not to be called")
+	}
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
 	}
 }
 
-func fnThatReturnsTrue() bool {
-	return true
+func TestEventContextCanceledDuringCallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+					Callbacks: state52.Callbacks{
+						"before": func(sm *state52.State52, e *state52.Event) error {
+							cancel()
+							return nil
+						},
+					},
+				},
+			},
+		),
+	)
+
+	err := sm.EventContext(ctx, "first_event")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected error to wrap context.Canceled, got %v", err)
+	}
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
+	}
 }
 
-func fnThatReturnsFalse() bool {
-	return false
+func TestEventContextPassedToCallbacksAndPersistFn(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request-1")
+
+	var callbackValue, persistValue interface{}
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+					Callbacks: state52.Callbacks{
+						"before": func(sm *state52.State52, e *state52.Event) error {
+							callbackValue = e.Context().Value(ctxKey{})
+							return nil
+						},
+					},
+				},
+			},
+		),
+		state52.SetPersistFnContext(func(ctx context.Context, newState string) error {
+			persistValue = ctx.Value(ctxKey{})
+			return nil
+		}),
+	)
+
+	err := sm.EventContext(ctx, "first_event")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if callbackValue != "request-1" || persistValue != "request-1" {
+		t.Errorf("expected context to reach callback & persistFn, got callback: %v, persistFn: %v", callbackValue, persistValue)
+	}
+}
+
+func TestEventArgs(t *testing.T) {
+	var beforeArgs, afterArgs, ensureArgs, successArgs []interface{}
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "pay",
+					Transitions: state52.Transitions{
+						{From: []string{"unpaid"}, To: "paid",
+							Callbacks: state52.TransitionCallbacks{
+								"success": func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+									successArgs = e.Args()
+									return nil
+								},
+							},
+						},
+					},
+					Callbacks: state52.Callbacks{
+						"before": func(sm *state52.State52, e *state52.Event) error {
+							beforeArgs = e.Args()
+							return nil
+						},
+						"after": func(sm *state52.State52, e *state52.Event) error {
+							afterArgs = e.Args()
+							return nil
+						},
+						"ensure": func(sm *state52.State52, e *state52.Event) error {
+							ensureArgs = e.Args()
+							return nil
+						},
+					},
+				},
+			},
+		),
+	)
+
+	err := sm.Event("pay", 150, "GBP")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "paid" {
+		t.Errorf("expected state to be 'paid', got %s", sm.CurrentState())
+	}
+
+	for name, args := range map[string][]interface{}{"before": beforeArgs, "after": afterArgs, "ensure": ensureArgs, "success": successArgs} {
+		if len(args) != 2 || args[0] != 150 || args[1] != "GBP" {
+			t.Errorf("expected %s callback to receive [150 GBP], got %v", name, args)
+		}
+	}
 }
+
+func TestAfterTransitionCallbackErrorAbortsEvent(t *testing.T) {
+	afterErr := errors.New("after failed")
+	persisted := false
+	var ensureSaw error
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first",
+							Callbacks: state52.TransitionCallbacks{
+								"after": func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+									return afterErr
+								},
+							},
+						},
+					},
+					Callbacks: state52.Callbacks{
+						"ensure": func(sm *state52.State52, e *state52.Event) error {
+							ensureSaw = e.Err()
+							return nil
+						},
+					},
+				},
+			},
+		),
+		state52.SetPersistFn(func(newState string) error {
+			persisted = true
+			return nil
+		}),
+	)
+
+	err := sm.Event("first_event")
+	if err != afterErr {
+		t.Errorf("expected error to be: %s, got %v", afterErr, err)
+	}
+
+	if ensureSaw != afterErr {
+		t.Errorf("expected ensure callback to see: %s, got %v", afterErr, ensureSaw)
+	}
+
+	if persisted {
+		t.Errorf("expected persistFn not to be called")
+	}
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
+	}
+}
+
+func TestCallbackErrorsAreJoined(t *testing.T) {
+	successErr := errors.New("success failed")
+	afterErr := errors.New("after failed")
+	afterAllErr := errors.New("after_all_events failed")
+	ensureErr := errors.New("ensure failed")
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first",
+							Callbacks: state52.TransitionCallbacks{
+								"success": func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+									return successErr
+								},
+							},
+						},
+					},
+					Callbacks: state52.Callbacks{
+						"after": func(sm *state52.State52, e *state52.Event) error {
+							return afterErr
+						},
+						"ensure": func(sm *state52.State52, e *state52.Event) error {
+							return ensureErr
+						},
+					},
+				},
+			},
+		),
+		state52.SetGlobalCallbacks(
+			state52.Callbacks{
+				"after_all_events": func(sm *state52.State52, e *state52.Event) error {
+					return afterAllErr
+				},
+			},
+		),
+	)
+
+	err := sm.Event("first_event")
+	for _, expected := range []error{successErr, afterErr, afterAllErr, ensureErr} {
+		if !errors.Is(err, expected) {
+			t.Errorf("expected error to include: %s, got %v", expected, err)
+		}
+	}
+
+	if sm.CurrentState() != "succeeded_first" {
+		t.Errorf("expected state to be 'succeeded_first', got %s", sm.CurrentState())
+	}
+}
+
+func TestEnsureErrorDoesNotMaskPrimaryError(t *testing.T) {
+	ensureErr := errors.New("ensure failed")
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first", Guards: state52.Guards{fnThatReturnsFalse}},
+					},
+				},
+			},
+		),
+		state52.SetGlobalCallbacks(
+			state52.Callbacks{
+				"ensure_all_events": func(sm *state52.State52, e *state52.Event) error {
+					return ensureErr
+				},
+			},
+		),
+	)
+
+	err := sm.Event("first_event")
+
+	var cannotTransition state52.CannotTransitionError
+	if !errors.As(err, &cannotTransition) {
+		t.Errorf("expected error to include a CannotTransitionError, got %v", err)
+	}
+
+	if !errors.Is(err, ensureErr) {
+		t.Errorf("expected error to include: %s, got %v", ensureErr, err)
+	}
+}
+
+func TestPersistFailureLeavesStateUnchanged(t *testing.T) {
+	persistErr := errors.New("database unavailable")
+	stateDuringPersist := ""
+	successCalled := false
+
+	var sm *state52.State52
+	sm = state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first",
+							Callbacks: state52.TransitionCallbacks{
+								"success": func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+									successCalled = true
+									return nil
+								},
+							},
+						},
+					},
+				},
+			},
+		),
+		state52.SetPersistFn(func(newState string) error {
+			stateDuringPersist = sm.CurrentState()
+			return persistErr
+		}),
+	)
+
+	err := sm.Event("first_event")
+
+	var persistFailed state52.PersistFailedError
+	if !errors.As(err, &persistFailed) {
+		t.Fatalf("expected a PersistFailedError, got %v", err)
+	}
+
+	if persistFailed.From != "start" || persistFailed.To != "succeeded_first" {
+		t.Errorf("expected From: start, To: succeeded_first, got From: %s, To: %s", persistFailed.From, persistFailed.To)
+	}
+
+	if !errors.Is(err, persistErr) {
+		t.Errorf("expected error to wrap: %s, got %v", persistErr, err)
+	}
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
+	}
+
+	if stateDuringPersist != "start" {
+		t.Errorf("expected state during persist to be 'start', got %s", stateDuringPersist)
+	}
+
+	if successCalled {
+		t.Errorf("expected success callback not to be called")
+	}
+}
+
+func TestOnExitRunsBeforePersist(t *testing.T) {
+	exited := 0
+	enteredNewState := false
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+			},
+		),
+		state52.SetStateCallbacks("start", state52.StateCallbacks{
+			"on_exit": func(sm *state52.State52, e *state52.Event, state string) error {
+				exited++
+				return nil
+			},
+		}),
+		state52.SetStateCallbacks("succeeded_first", state52.StateCallbacks{
+			"on_enter": func(sm *state52.State52, e *state52.Event, state string) error {
+				enteredNewState = true
+				return nil
+			},
+		}),
+		state52.SetPersistFn(func(newState string) error {
+			return errors.New("database unavailable")
+		}),
+	)
+
+	err := sm.Event("first_event")
+
+	var persistFailed state52.PersistFailedError
+	if !errors.As(err, &persistFailed) {
+		t.Fatalf("expected a PersistFailedError, got %v", err)
+	}
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
+	}
+
+	if exited != 1 {
+		t.Errorf("expected on_exit to have been called once before the persist failed, got %d", exited)
+	}
+
+	if enteredNewState {
+		t.Errorf("expected on_enter not to be called")
+	}
+}
+
+func TestSetCurrentState(t *testing.T) {
+	sm := state52.NewStateMachine(
+		state52.SetCurrentState("succeeded_first"),
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+				{
+					Name: "second_event",
+					Transitions: state52.Transitions{
+						{From: []string{"succeeded_first"}, To: "succeeded_second"},
+					},
+				},
+			},
+		),
+	)
+
+	if sm.CurrentState() != "succeeded_first" {
+		t.Errorf("expected state to be 'succeeded_first', got %s", sm.CurrentState())
+	}
+
+	err := sm.Event("second_event")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "succeeded_second" {
+		t.Errorf("expected state to be 'succeeded_second', got %s", sm.CurrentState())
+	}
+}
+
+func TestSetLoadFn(t *testing.T) {
+	events := state52.Events{
+		{
+			Name: "first_event",
+			Transitions: state52.Transitions{
+				{From: []string{"start"}, To: "succeeded_first"},
+			},
+		},
+	}
+
+	saved := ""
+	load := func() (string, error) {
+		return saved, nil
+	}
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(events),
+		state52.SetLoadFn(load),
+		state52.SetPersistFn(func(newState string) error {
+			saved = newState
+			return nil
+		}),
+	)
+
+	if sm.CurrentState() != "start" {
+		t.Errorf("expected state to be 'start', got %s", sm.CurrentState())
+	}
+
+	sm.Event("first_event")
+
+	restored := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(events),
+		state52.SetLoadFn(load),
+	)
+
+	if restored.CurrentState() != "succeeded_first" {
+		t.Errorf("expected state to be 'succeeded_first', got %s", restored.CurrentState())
+	}
+}
+
+func TestSetCurrentStateNotRegistered(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Expected panic due to an unregistered restored state, but no panic was thrown.")
+		}
+	}()
+
+	state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetCurrentState("not_a_state"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+			},
+		),
+	)
+}
+
+func TestConcurrentEventsAreSequential(t *testing.T) {
+	var persistCount int
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first",
+							Callbacks: state52.TransitionCallbacks{
+								"after": func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+									time.Sleep(10 * time.Millisecond)
+									return nil
+								},
+							},
+						},
+					},
+				},
+			},
+		),
+		state52.SetPersistFn(func(newState string) error {
+			persistCount++
+			return nil
+		}),
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- sm.Event("first_event")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+
+	if succeeded != 1 || persistCount != 1 {
+		t.Errorf("expected exactly 1 event to succeed & persist, got %d succeeded, %d persisted", succeeded, persistCount)
+	}
+
+	if sm.CurrentState() != "succeeded_first" {
+		t.Errorf("expected state to be 'succeeded_first', got %s", sm.CurrentState())
+	}
+}
+
+func TestEventContextCanceledWhileWaiting(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first",
+							Callbacks: state52.TransitionCallbacks{
+								"after": func(sm *state52.State52, e *state52.Event, t *state52.Transition) error {
+									close(entered)
+									<-release
+									return nil
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: "second_event",
+					Transitions: state52.Transitions{
+						{From: []string{"succeeded_first"}, To: "succeeded_second"},
+					},
+				},
+			},
+		),
+	)
+
+	done := make(chan error)
+	go func() { done <- sm.Event("first_event") }()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := sm.EventContext(ctx, "second_event")
+	var canceledErr state52.EventCanceledError
+	if !errors.As(err, &canceledErr) || canceledErr.EventName != "second_event" {
+		t.Errorf("expected an EventCanceledError for second_event, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("expected first_event to succeed, got %v", err)
+	}
+
+	if sm.CurrentState() != "succeeded_first" {
+		t.Errorf("expected state to be 'succeeded_first', got %s", sm.CurrentState())
+	}
+}
+
+func TestRaiseRunsAfterEventCompletes(t *testing.T) {
+	order := []string{}
+	record := func(name string) state52.Callbacks {
+		return state52.Callbacks{
+			"before": func(sm *state52.State52, e *state52.Event) error {
+				order = append(order, name+":before")
+				return nil
+			},
+			"after": func(sm *state52.State52, e *state52.Event) error {
+				order = append(order, name+":after")
+				if name == "first_event" {
+					return sm.Raise("second_event", "arg")
+				}
+				return nil
+			},
+			"ensure": func(sm *state52.State52, e *state52.Event) error {
+				order = append(order, name+":ensure")
+				return nil
+			},
+		}
+	}
+
+	var secondArgs []interface{}
+	secondCallbacks := record("second_event")
+	secondBefore := secondCallbacks["before"]
+	secondCallbacks["before"] = func(sm *state52.State52, e *state52.Event) error {
+		secondArgs = e.Args()
+		return secondBefore(sm, e)
+	}
+
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+					Callbacks: record("first_event"),
+				},
+				{
+					Name: "second_event",
+					Transitions: state52.Transitions{
+						{From: []string{"succeeded_first"}, To: "succeeded_second"},
+					},
+					Callbacks: secondCallbacks,
+				},
+			},
+		),
+		state52.SetGlobalCallbacks(
+			state52.Callbacks{
+				"after_all_events": func(sm *state52.State52, e *state52.Event) error {
+					order = append(order, e.Name+":after_all_events")
+					return nil
+				},
+			},
+		),
+	)
+
+	err := sm.Event("first_event")
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if sm.CurrentState() != "succeeded_second" {
+		t.Errorf("expected state to be 'succeeded_second', got %s", sm.CurrentState())
+	}
+
+	expectedOrder := []string{
+		"first_event:before", "first_event:after", "first_event:after_all_events", "first_event:ensure",
+		"second_event:before", "second_event:after", "second_event:after_all_events", "second_event:ensure",
+	}
+	if fmt.Sprint(order) != fmt.Sprint(expectedOrder) {
+		t.Errorf("expected callback order to be: %v, got %v", expectedOrder, order)
+	}
+
+	if len(secondArgs) != 1 || secondArgs[0] != "arg" {
+		t.Errorf("expected second_event args to be [arg], got %v", secondArgs)
+	}
+}
+
+func TestRaisedEventErrorIsReturned(t *testing.T) {
+	sm := state52.NewStateMachine(
+		state52.SetInitial("start"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+					Callbacks: state52.Callbacks{
+						"after": func(sm *state52.State52, e *state52.Event) error {
+							return sm.Raise("second_event")
+						},
+					},
+				},
+				{
+					Name: "second_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_second"},
+					},
+				},
+			},
+		),
+	)
+
+	err := sm.Event("first_event")
+
+	var cannotTransition state52.CannotTransitionError
+	if !errors.As(err, &cannotTransition) || cannotTransition.EventName != "second_event" {
+		t.Errorf("expected a CannotTransitionError for second_event, got %v", err)
+	}
+
+	if sm.CurrentState() != "succeeded_first" {
+		t.Errorf("expected state to be 'succeeded_first', got %s", sm.CurrentState())
+	}
+}
+
+func TestFinalStates(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("start"),
+		state52.SetFinalStates("completed"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+				{
+					Name: "second_event",
+					Transitions: state52.Transitions{
+						{From: []string{"succeeded_first"}, To: "completed"},
+					},
+				},
+				{
+					Name: "restart",
+					Transitions: state52.Transitions{
+						{From: []string{"completed"}, To: "start"},
+					},
+				},
+			},
+		),
+	)
+
+	sm.Event("first_event")
+	select {
+	case <-sm.Done():
+		t.Errorf("expected Done() not to be closed in a non-final state")
+	default:
+	}
+
+	if sm.IsFinal() {
+		t.Errorf("expected 'succeeded_first' not to be final")
+	}
+
+	sm.Event("second_event")
+	select {
+	case <-sm.Done():
+	default:
+		t.Errorf("expected Done() to be closed in a final state")
+	}
+
+	if !sm.IsFinal() {
+		t.Errorf("expected 'completed' to be final")
+	}
+
+	err := sm.Event("restart")
+	var completedErr state52.MachineCompletedError
+	if !errors.As(err, &completedErr) || completedErr.CurrentState != "completed" {
+		t.Errorf("expected a MachineCompletedError in completed, got %v", err)
+	}
+
+	if sm.CurrentState() != "completed" {
+		t.Errorf("expected state to be 'completed', got %s", sm.CurrentState())
+	}
+}
+
+func TestFinalStateNotRegistered(t *testing.T) {
+	_, err := state52.New(
+		state52.SetInitial("start"),
+		state52.SetFinalStates("not_a_state"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "first_event",
+					Transitions: state52.Transitions{
+						{From: []string{"start"}, To: "succeeded_first"},
+					},
+				},
+			},
+		),
+	)
+
+	var validationErr state52.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("expected a ValidationError, got %v", err)
+	}
+}
+
+func TestEventNotRegisteredError(t *testing.T) {
+	eventName := "not_an_event"
+
+	e := state52.EventNotRegisteredError{eventName}
+	if e.Error() != fmt.Sprintf("%s is not registered.", e.EventName) {
+		t.Errorf("Expected %s, Got: %s", fmt.Sprintf("%s is not registered.", e.EventName), e.Error())
+	}
+}
+
+func TestCannotTransitionError(t *testing.T) {
+	eventName := "not_an_event"
+	currentState := "initial"
+
+	e := state52.CannotTransitionError{CurrentState: currentState, EventName: eventName}
+	if e.Error() != fmt.Sprintf("Cannot transition from %s when calling %s.", e.CurrentState, e.EventName) {
+		t.Errorf("Expected %s, Got: %s", fmt.Sprintf("Cannot transition from %s when calling %s.", e.CurrentState, e.EventName), e.Error())
+	}
+}
+
+func TestEventCanceledError(t *testing.T) {
+	e := state52.EventCanceledError{"event", context.DeadlineExceeded}
+	if e.Error() != fmt.Sprintf("%s was canceled: %s", e.EventName, e.Err) {
+		t.Errorf("Expected %s, Got: %s", fmt.Sprintf("%s was canceled: %s", e.EventName, e.Err), e.Error())
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	e := state52.ValidationError{[]string{"You must set an initial state.", "You must define at least 1 event."}}
+	expected := "Invalid state machine: You must set an initial state. You must define at least 1 event."
+	if e.Error() != expected {
+		t.Errorf("Expected %s, Got: %s", expected, e.Error())
+	}
+}
+
+func TestMachineCompletedError(t *testing.T) {
+	e := state52.MachineCompletedError{"completed", "restart"}
+	if e.Error() != fmt.Sprintf("Cannot call %s as the state machine completed in %s.", e.EventName, e.CurrentState) {
+		t.Errorf("Expected %s, Got: %s", fmt.Sprintf("Cannot call %s as the state machine completed in %s.", e.EventName, e.CurrentState), e.Error())
+	}
+}
+
+func TestPersistFailedError(t *testing.T) {
+	eventName := "event"
+	message := errors.New("something broke")
+
+	e := state52.PersistFailedError{Message: message, EventName: eventName, From: "start", To: "finish"}
+	if e.Error() != fmt.Sprintf("Perist failed for %s: %s", e.EventName, e.Message) {
+		t.Errorf("Expected %s, Got: %s", fmt.Sprintf("Perist failed for %s: %s", e.EventName, e.Message), e.Error())
+	}
+}
+
+var fnThatReturnsTrue = state52.Condition(func() bool {
+	return true
+})
+
+var fnThatReturnsFalse = state52.Condition(func() bool {
+	return false
+})
@@ -0,0 +1,76 @@
+package state52
+
+import "context"
+
+// timedTransition is a transition with an After duration, waiting on
+// its timer. It was started when its From state was entered.
+type timedTransition struct {
+	event string
+	index int
+	state string
+	timer Timer
+}
+
+// startTimers starts the timer of each timed transition from
+// the entered states. The eventLock must be held.
+func (sm *State52) startTimers(entered []string) {
+	if sm.stopped || sm.IsFinal() {
+		return
+	}
+
+	for _, state := range entered {
+		for _, name := range sortedKeys(sm.events) {
+			for i, transition := range sm.events[name].Transitions {
+				if transition.After <= 0 || !stringInSlice(state, transition.From) {
+					continue
+				}
+
+				t := &timedTransition{event: name, index: i, state: state}
+				t.timer = sm.clock.AfterFunc(transition.After, func() { sm.fireTimed(t) })
+				sm.timed[state] = append(sm.timed[state], t)
+			}
+		}
+	}
+}
+
+// stopTimers stops the timers of the timed transitions
+// from the exited states. The eventLock must be held.
+func (sm *State52) stopTimers(exited []string) {
+	for _, state := range exited {
+		for _, t := range sm.timed[state] {
+			t.timer.Stop()
+		}
+		delete(sm.timed, state)
+	}
+}
+
+// fireTimed performs a timed transition once its timer has fired, unless
+// its From state was exited in the meantime. Errors are not returned
+// to a caller, but are passed to observers & recorded in the history.
+func (sm *State52) fireTimed(t *timedTransition) {
+	sm.eventLock.lock()
+	defer sm.eventLock.unlock()
+
+	pending := sm.timed[t.state]
+	for i := range pending {
+		if pending[i] == t {
+			sm.timed[t.state] = append(pending[:i:i], pending[i+1:]...)
+			sm.drain(context.Background(), t.event, nil, t)
+			return
+		}
+	}
+}
+
+// Stop stops the timers of any timed transitions & no further timers
+// are started. Events can still be fired. Call Stop when a state machine
+// with timed transitions is no longer needed. Within a callback, call
+// Stop on the State52 it is passed.
+func (sm *State52) Stop() {
+	if !sm.nested {
+		sm.eventLock.lock()
+		defer sm.eventLock.unlock()
+	}
+
+	sm.stopped = true
+	sm.stopTimers(sortedKeys(sm.timed))
+}
@@ -0,0 +1,156 @@
+package state52_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benhawker/state52"
+	"github.com/benhawker/state52/state52test"
+)
+
+var timedEvents = state52.Events{
+	{
+		Name: "pay",
+		Transitions: state52.Transitions{
+			{From: []string{"unpaid"}, To: "paid"},
+		},
+	},
+	{
+		Name: "remind",
+		Transitions: state52.Transitions{
+			{From: []string{"unpaid"}, To: "unpaid"},
+		},
+	},
+	{
+		Name: "expire",
+		Transitions: state52.Transitions{
+			{From: []string{"unpaid"}, To: "cancelled", After: 30 * time.Minute},
+		},
+	},
+}
+
+func TestTimedTransition(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := state52test.NewFakeClock(start)
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetClock(clock),
+		state52.SetHistory(10),
+		state52.SetEvents(timedEvents),
+	)
+
+	clock.Advance(29 * time.Minute)
+	if sm.CurrentState() != "unpaid" {
+		t.Errorf("expected state to be 'unpaid', got %s", sm.CurrentState())
+	}
+
+	clock.Advance(time.Minute)
+	if sm.CurrentState() != "cancelled" {
+		t.Errorf("expected state to be 'cancelled', got %s", sm.CurrentState())
+	}
+
+	history := sm.History()
+	if len(history) != 1 || history[0].EventName != "expire" || !history[0].Time.Equal(start.Add(30*time.Minute)) {
+		t.Errorf("expected expire to be recorded at %s, got %v", start.Add(30*time.Minute), history)
+	}
+}
+
+func TestTimedTransitionStateLeft(t *testing.T) {
+	clock := state52test.NewFakeClock(time.Now())
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetClock(clock),
+		state52.SetHistory(10),
+		state52.SetEvents(timedEvents),
+	)
+
+	clock.Advance(10 * time.Minute)
+	sm.Event("pay")
+	clock.Advance(time.Hour)
+
+	if sm.CurrentState() != "paid" {
+		t.Errorf("expected state to be 'paid', got %s", sm.CurrentState())
+	}
+}
+
+func TestTimedTransitionStateReentered(t *testing.T) {
+	clock := state52test.NewFakeClock(time.Now())
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetClock(clock),
+		state52.SetHistory(10),
+		state52.SetEvents(timedEvents),
+	)
+
+	clock.Advance(20 * time.Minute)
+	sm.Event("remind")
+
+	clock.Advance(20 * time.Minute)
+	if sm.CurrentState() != "unpaid" {
+		t.Errorf("expected state to be 'unpaid', got %s", sm.CurrentState())
+	}
+
+	clock.Advance(10 * time.Minute)
+	if sm.CurrentState() != "cancelled" {
+		t.Errorf("expected state to be 'cancelled', got %s", sm.CurrentState())
+	}
+}
+
+func TestTimedTransitionEvent(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetClock(state52test.NewFakeClock(time.Now())),
+		state52.SetHistory(10),
+		state52.SetEvents(timedEvents),
+	)
+
+	err := sm.Event("expire")
+	expectedError := "Cannot transition from unpaid when calling expire."
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("expected error message to be: %s, got %v", expectedError, err)
+	}
+
+	if sm.Can("expire") {
+		t.Errorf("expected Can to be false for a timed transition")
+	}
+}
+
+func TestStop(t *testing.T) {
+	clock := state52test.NewFakeClock(time.Now())
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetClock(clock),
+		state52.SetHistory(10),
+		state52.SetEvents(timedEvents),
+	)
+
+	sm.Stop()
+	clock.Advance(time.Hour)
+
+	if sm.CurrentState() != "unpaid" {
+		t.Errorf("expected state to be 'unpaid', got %s", sm.CurrentState())
+	}
+}
+
+func TestAnalyzeTimedTransitions(t *testing.T) {
+	sm := state52.MustNew(
+		state52.SetInitial("unpaid"),
+		state52.SetEvents(
+			state52.Events{
+				{
+					Name: "expire",
+					Transitions: state52.Transitions{
+						{From: []string{"unpaid"}, To: "cancelled", After: 30 * time.Minute},
+						{From: []string{"unpaid"}, To: "expired"},
+						{From: []string{"unpaid"}, To: "archived", After: time.Hour},
+					},
+				},
+			},
+		),
+	)
+	defer sm.Stop()
+
+	if report := sm.Analyze(); len(report.Shadowed) != 0 {
+		t.Errorf("expected timed transitions neither to shadow nor be shadowed, got %+v", report.Shadowed)
+	}
+}
@@ -0,0 +1,466 @@
+package state52
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Machine is a state machine whose states & events are of user defined
+// types, e.g. `type OrderState string` or an enum of `type OrderState int`
+// constants, so an unknown state or event is a compile time error.
+// Machine[string, string] has the same behaviour as a State52.
+//
+// The name of a state or event, as used by the State52 it wraps (e.g. when
+// exported or persisted), is fmt.Sprint(value): its String() if it has one.
+// Distinct states (or events) must have distinct names.
+type Machine[S, E comparable] struct {
+	sm *State52
+}
+
+// Option configures a Machine[S, E], as a SetupFunc configures a State52.
+// Options are built with the methods of Typed[S, E], so any states,
+// events & callbacks they take are of the Machine's types.
+type Option[S, E comparable] func(*State52) error
+
+// Typed builds the Options of a Machine[S, E]. It has no state, so
+// declare one per state machine definition & use its methods:
+//
+//	var order state52.Typed[OrderState, OrderEvent]
+//	m := state52.MustNewMachine(order.Initial(Pending), order.Events(events))
+type Typed[S, E comparable] struct{}
+
+// Firing is the event being fired, as passed to the guards & callbacks of
+// a Machine[S, E]. It embeds the Event, for its Context(), Args() & Err().
+type Firing[E comparable] struct {
+	*Event
+
+	// Name is the name of the event being fired.
+	Name E
+}
+
+// TypedEvent is an Event whose name, transitions & callbacks are typed.
+type TypedEvent[S, E comparable] struct {
+	Name        E
+	Transitions []TypedTransition[S, E]
+	Guards      []Guard
+	Callbacks   TypedCallbacks[S, E]
+	Hooks       TypedHooks[S, E]
+}
+
+// TypedEvents is a slice of TypedEvent.
+type TypedEvents[S, E comparable] []TypedEvent[S, E]
+
+// TypedTransition is a Transition whose states & callbacks are typed.
+type TypedTransition[S, E comparable] struct {
+	From      []S
+	To        S
+	Guards    []Guard
+	Callbacks TypedTransitionCallbacks[S, E]
+	Hooks     TypedTransitionHooks[S, E]
+	After     time.Duration
+	History   HistoryMode
+}
+
+// TypedTransitions is a slice of TypedTransition.
+type TypedTransitions[S, E comparable] []TypedTransition[S, E]
+
+// TypedCallbacks are the Callbacks of a Machine[S, E].
+type TypedCallbacks[S, E comparable] map[string]typedCallback[S, E]
+
+// typedCallback is a callback of a Machine[S, E].
+type typedCallback[S, E comparable] func(*Machine[S, E], *Firing[E]) error
+
+// TypedTransitionCallbacks are the TransitionCallbacks of a Machine[S, E].
+type TypedTransitionCallbacks[S, E comparable] map[string]typedTCallback[S, E]
+
+// typedTCallback is a Transition callback of a Machine[S, E].
+type typedTCallback[S, E comparable] func(*Machine[S, E], *Firing[E], *TypedTransition[S, E]) error
+
+// TypedStateCallbacks are the StateCallbacks of a Machine[S, E].
+type TypedStateCallbacks[S, E comparable] map[string]typedSCallback[S, E]
+
+// typedSCallback is a State callback of a Machine[S, E].
+// state is the state being entered or exited.
+type typedSCallback[S, E comparable] func(*Machine[S, E], *Firing[E], S) error
+
+// TypedHook is a Hook of a Machine[S, E].
+type TypedHook[S, E comparable] struct {
+	Name     string
+	Priority int
+	Fn       typedCallback[S, E]
+}
+
+// TypedHooks are the Hooks of a Machine[S, E].
+type TypedHooks[S, E comparable] map[string][]TypedHook[S, E]
+
+// TypedTransitionHook is a TransitionHook of a Machine[S, E].
+type TypedTransitionHook[S, E comparable] struct {
+	Name     string
+	Priority int
+	Fn       typedTCallback[S, E]
+}
+
+// TypedTransitionHooks are the TransitionHooks of a Machine[S, E].
+type TypedTransitionHooks[S, E comparable] map[string][]TypedTransitionHook[S, E]
+
+// TypedStateHook is a StateHook of a Machine[S, E].
+type TypedStateHook[S, E comparable] struct {
+	Name     string
+	Priority int
+	Fn       typedSCallback[S, E]
+}
+
+// TypedStateHooks are the StateHooks of a Machine[S, E].
+type TypedStateHooks[S, E comparable] map[string][]TypedStateHook[S, E]
+
+// Initial sets the initialState, as SetInitial.
+func (Typed[S, E]) Initial(state S) Option[S, E] {
+	return withNames[S, E]([]S{state}, nil, SetInitial(valueName(state)))
+}
+
+// CurrentState restores a previously persisted state, as SetCurrentState.
+func (Typed[S, E]) CurrentState(state S) Option[S, E] {
+	return withNames[S, E]([]S{state}, nil, SetCurrentState(valueName(state)))
+}
+
+// FinalStates sets the states in which the state machine is complete, as SetFinalStates.
+func (Typed[S, E]) FinalStates(states ...S) Option[S, E] {
+	return withNames[S, E](states, nil, SetFinalStates(valueNames(states)...))
+}
+
+// Substates makes parent a composite state, as SetSubstates.
+func (Typed[S, E]) Substates(parent S, substates ...S) Option[S, E] {
+	return withNames[S, E](append([]S{parent}, substates...), nil, SetSubstates(valueName(parent), valueNames(substates)...))
+}
+
+// Region adds an orthogonal region starting in initial, as SetRegion.
+func (Typed[S, E]) Region(name string, initial S) Option[S, E] {
+	return withNames[S, E]([]S{initial}, nil, SetRegion(name, valueName(initial)))
+}
+
+// Events sets the events, as SetEvents.
+func (Typed[S, E]) Events(events TypedEvents[S, E]) Option[S, E] {
+	untyped := Events{}
+	states := []S{}
+	names := []E{}
+	for _, event := range events {
+		names = append(names, event.Name)
+		e := Event{
+			Name:      valueName(event.Name),
+			Guards:    event.Guards,
+			Callbacks: untypedCallbacks(event.Callbacks),
+			Hooks:     untypedHooks(event.Hooks),
+		}
+
+		for _, transition := range event.Transitions {
+			states = append(append(states, transition.From...), transition.To)
+			e.Transitions = append(e.Transitions, untypedTransition(transition))
+		}
+
+		untyped = append(untyped, e)
+	}
+
+	return withNames(states, names, SetEvents(untyped))
+}
+
+// GlobalCallbacks sets the 'global' callbacks, as SetGlobalCallbacks.
+func (Typed[S, E]) GlobalCallbacks(callbacks TypedCallbacks[S, E]) Option[S, E] {
+	return Option[S, E](SetGlobalCallbacks(untypedCallbacks(callbacks)))
+}
+
+// GlobalHooks sets the 'global' hooks, as SetGlobalHooks.
+func (Typed[S, E]) GlobalHooks(hooks TypedHooks[S, E]) Option[S, E] {
+	return Option[S, E](SetGlobalHooks(untypedHooks(hooks)))
+}
+
+// StateCallbacks sets the callbacks for state, as SetStateCallbacks.
+func (Typed[S, E]) StateCallbacks(state S, callbacks TypedStateCallbacks[S, E]) Option[S, E] {
+	return withNames[S, E]([]S{state}, nil, SetStateCallbacks(valueName(state), untypedStateCallbacks(callbacks)))
+}
+
+// StateHooks sets the hooks for state, as SetStateHooks.
+func (Typed[S, E]) StateHooks(state S, hooks TypedStateHooks[S, E]) Option[S, E] {
+	return withNames[S, E]([]S{state}, nil, SetStateHooks(valueName(state), untypedStateHooks(hooks)))
+}
+
+// GlobalStateCallbacks sets the callbacks for every state, as SetGlobalStateCallbacks.
+func (Typed[S, E]) GlobalStateCallbacks(callbacks TypedStateCallbacks[S, E]) Option[S, E] {
+	return Option[S, E](SetGlobalStateCallbacks(untypedStateCallbacks(callbacks)))
+}
+
+// GlobalStateHooks sets the hooks for every state, as SetGlobalStateHooks.
+func (Typed[S, E]) GlobalStateHooks(hooks TypedStateHooks[S, E]) Option[S, E] {
+	return Option[S, E](SetGlobalStateHooks(untypedStateHooks(hooks)))
+}
+
+// With adapts a SetupFunc that takes no states, events or callbacks,
+// e.g. SetPersistFn, SetHistory or SetClock, to an Option.
+func (Typed[S, E]) With(option SetupFunc) Option[S, E] {
+	return Option[S, E](option)
+}
+
+// Guard adapts fn to a Guard, as GuardFunc.
+func (Typed[S, E]) Guard(fn func(*Firing[E]) bool) Guard {
+	return GuardFunc(func(e *Event) bool {
+		return fn(newFiring[E](e))
+	})
+}
+
+// Check adapts fn to a Guard that can also return a Rejection or an error, as CheckFunc.
+func (Typed[S, E]) Check(fn func(*Firing[E]) (bool, error)) Guard {
+	return CheckFunc(func(e *Event) (bool, error) {
+		return fn(newFiring[E](e))
+	})
+}
+
+// NewMachine creates a Machine[S, E], as New creates a State52.
+func NewMachine[S, E comparable](options ...Option[S, E]) (*Machine[S, E], error) {
+	setup := make([]SetupFunc, len(options))
+	for i, option := range options {
+		setup[i] = SetupFunc(option)
+	}
+
+	sm, err := New(setup...)
+	if err != nil {
+		return nil, err
+	}
+	return &Machine[S, E]{sm}, nil
+}
+
+// MustNewMachine is like NewMachine but panics if the options are invalid.
+func MustNewMachine[S, E comparable](options ...Option[S, E]) *Machine[S, E] {
+	m, err := NewMachine(options...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Untyped returns the State52 the Machine wraps, e.g. to export it
+// or to pass to code written against the string API.
+func (m *Machine[S, E]) Untyped() *State52 {
+	return m.sm
+}
+
+// Event performs the first available transition that is found, as State52.Event.
+func (m *Machine[S, E]) Event(event E, args ...interface{}) error {
+	return m.sm.Event(valueName(event), args...)
+}
+
+// EventContext performs the first available transition that is found, as State52.EventContext.
+func (m *Machine[S, E]) EventContext(ctx context.Context, event E, args ...interface{}) error {
+	return m.sm.EventContext(ctx, valueName(event), args...)
+}
+
+// Raise fires an event once the event being fired has completed, as State52.Raise.
+func (m *Machine[S, E]) Raise(event E, args ...interface{}) error {
+	return m.sm.Raise(valueName(event), args...)
+}
+
+// CurrentState returns the current state. With regions use Configuration.
+func (m *Machine[S, E]) CurrentState() S {
+	return valueOf[S](m.sm.stateValues, m.sm.CurrentState())
+}
+
+// Configuration returns the current state of each region, as State52.Configuration.
+func (m *Machine[S, E]) Configuration() map[string]S {
+	configuration := map[string]S{}
+	for name, state := range m.sm.Configuration() {
+		configuration[name] = valueOf[S](m.sm.stateValues, state)
+	}
+	return configuration
+}
+
+// In reports whether the state machine is in state, or in a substate of it.
+func (m *Machine[S, E]) In(state S) bool {
+	return m.sm.In(valueName(state))
+}
+
+// Can reports whether the event can be fired from the current state with args.
+func (m *Machine[S, E]) Can(event E, args ...interface{}) bool {
+	return m.sm.Can(valueName(event), args...)
+}
+
+// AvailableEvents returns the events with at least one
+// transition from the current state, sorted by name.
+func (m *Machine[S, E]) AvailableEvents() []E {
+	events := []E{}
+	for _, event := range m.sm.AvailableEvents() {
+		events = append(events, valueOf[E](m.sm.eventValues, event))
+	}
+	return events
+}
+
+// IsFinal reports whether the state machine is in a final state.
+func (m *Machine[S, E]) IsFinal() bool {
+	return m.sm.IsFinal()
+}
+
+// Done returns a channel that is closed when the state machine enters a final state.
+func (m *Machine[S, E]) Done() <-chan struct{} {
+	return m.sm.Done()
+}
+
+// Stop stops the timers of any timed transitions, as State52.Stop.
+func (m *Machine[S, E]) Stop() {
+	m.sm.Stop()
+}
+
+// newFiring returns the Firing for e.
+func newFiring[E comparable](e *Event) *Firing[E] {
+	return &Firing[E]{Event: e, Name: valueOf[E](e.machine.eventValues, e.Name)}
+}
+
+// untypedTransition returns the Transition for transition. Its callbacks
+// are passed a copy of transition rather than the Transition.
+func untypedTransition[S, E comparable](transition TypedTransition[S, E]) Transition {
+	callback := func(fn typedTCallback[S, E]) tCallback {
+		if fn == nil {
+			return nil
+		}
+		return func(sm *State52, e *Event, _ *Transition) error {
+			t := transition
+			return fn(&Machine[S, E]{sm}, newFiring[E](e), &t)
+		}
+	}
+
+	untyped := Transition{
+		From:      valueNames(transition.From),
+		To:        valueName(transition.To),
+		Guards:    transition.Guards,
+		Callbacks: TransitionCallbacks{},
+		Hooks:     TransitionHooks{},
+		After:     transition.After,
+		History:   transition.History,
+	}
+	for key, fn := range transition.Callbacks {
+		untyped.Callbacks[key] = callback(fn)
+	}
+	for key, hooks := range transition.Hooks {
+		for _, hook := range hooks {
+			untyped.Hooks[key] = append(untyped.Hooks[key], TransitionHook{hook.Name, hook.Priority, callback(hook.Fn)})
+		}
+	}
+	return untyped
+}
+
+// untypedCallback returns the callback for fn, which is nil if fn is.
+func untypedCallback[S, E comparable](fn typedCallback[S, E]) callback {
+	if fn == nil {
+		return nil
+	}
+	return func(sm *State52, e *Event) error {
+		return fn(&Machine[S, E]{sm}, newFiring[E](e))
+	}
+}
+
+// untypedCallbacks returns the Callbacks for callbacks.
+func untypedCallbacks[S, E comparable](callbacks TypedCallbacks[S, E]) Callbacks {
+	untyped := Callbacks{}
+	for key, fn := range callbacks {
+		untyped[key] = untypedCallback(fn)
+	}
+	return untyped
+}
+
+// untypedHooks returns the Hooks for hooks.
+func untypedHooks[S, E comparable](hooks TypedHooks[S, E]) Hooks {
+	untyped := Hooks{}
+	for key, list := range hooks {
+		for _, hook := range list {
+			untyped[key] = append(untyped[key], Hook{hook.Name, hook.Priority, untypedCallback(hook.Fn)})
+		}
+	}
+	return untyped
+}
+
+// untypedStateCallback returns the State callback for fn, which is nil if fn is.
+func untypedStateCallback[S, E comparable](fn typedSCallback[S, E]) sCallback {
+	if fn == nil {
+		return nil
+	}
+	return func(sm *State52, e *Event, state string) error {
+		return fn(&Machine[S, E]{sm}, newFiring[E](e), valueOf[S](sm.stateValues, state))
+	}
+}
+
+// untypedStateCallbacks returns the StateCallbacks for callbacks.
+func untypedStateCallbacks[S, E comparable](callbacks TypedStateCallbacks[S, E]) StateCallbacks {
+	untyped := StateCallbacks{}
+	for key, fn := range callbacks {
+		untyped[key] = untypedStateCallback(fn)
+	}
+	return untyped
+}
+
+// untypedStateHooks returns the StateHooks for hooks.
+func untypedStateHooks[S, E comparable](hooks TypedStateHooks[S, E]) StateHooks {
+	untyped := StateHooks{}
+	for key, list := range hooks {
+		for _, hook := range list {
+			untyped[key] = append(untyped[key], StateHook{hook.Name, hook.Priority, untypedStateCallback(hook.Fn)})
+		}
+	}
+	return untyped
+}
+
+// withNames returns an Option that registers the names of states &
+// events, so that they can be turned back into values, then applies option.
+func withNames[S, E comparable](states []S, events []E, option SetupFunc) Option[S, E] {
+	return func(sm *State52) error {
+		if err := registerNames(&sm.stateValues, states); err != nil {
+			return err
+		}
+		if err := registerNames(&sm.eventValues, events); err != nil {
+			return err
+		}
+		return option(sm)
+	}
+}
+
+// registerNames adds each value to values by its name. Distinct
+// values with the same name could not be told apart, so are an error.
+func registerNames[T comparable](values *map[string]interface{}, registered []T) error {
+	if *values == nil {
+		*values = map[string]interface{}{}
+	}
+
+	for _, value := range registered {
+		name := valueName(value)
+		if existing, ok := (*values)[name]; ok && existing != interface{}(value) {
+			return fmt.Errorf("Distinct %T values are both named %s.", value, name)
+		}
+		(*values)[name] = value
+	}
+	return nil
+}
+
+// valueOf returns the value of type T named name. A name that was not
+// registered, e.g. a state restored by an untyped option, is converted
+// to T if T is a string type, or else is T's zero value.
+func valueOf[T comparable](values map[string]interface{}, name string) T {
+	if value, ok := values[name].(T); ok {
+		return value
+	}
+
+	var value T
+	if v := reflect.ValueOf(&value).Elem(); v.Kind() == reflect.String {
+		v.SetString(name)
+	}
+	return value
+}
+
+// valueName returns the name of a state or event, see Machine.
+func valueName[T comparable](value T) string {
+	return fmt.Sprint(value)
+}
+
+func valueNames[T comparable](values []T) []string {
+	names := make([]string, len(values))
+	for i, value := range values {
+		names[i] = valueName(value)
+	}
+	return names
+}
@@ -0,0 +1,261 @@
+package state52_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/benhawker/state52"
+)
+
+type orderState string
+type orderEvent string
+
+const (
+	pending   orderState = "pending"
+	paid      orderState = "paid"
+	cancelled orderState = "cancelled"
+
+	pay    orderEvent = "pay"
+	cancel orderEvent = "cancel"
+)
+
+type orderMachine = state52.Machine[orderState, orderEvent]
+type orderFiring = state52.Firing[orderEvent]
+
+var order state52.Typed[orderState, orderEvent]
+
+var typedOrderEvents = state52.TypedEvents[orderState, orderEvent]{
+	{
+		Name: pay,
+		Transitions: state52.TypedTransitions[orderState, orderEvent]{
+			{From: []orderState{pending}, To: paid},
+		},
+	},
+	{
+		Name: cancel,
+		Transitions: state52.TypedTransitions[orderState, orderEvent]{
+			{From: []orderState{pending, paid}, To: cancelled},
+		},
+	},
+}
+
+func TestMachine(t *testing.T) {
+	m := state52.MustNewMachine(
+		order.Initial(pending),
+		order.FinalStates(cancelled),
+		order.Events(typedOrderEvents),
+	)
+
+	if m.CurrentState() != pending {
+		t.Errorf("expected state to be %s, got %s", pending, m.CurrentState())
+	}
+
+	expectedEvents := []orderEvent{cancel, pay}
+	if fmt.Sprint(m.AvailableEvents()) != fmt.Sprint(expectedEvents) {
+		t.Errorf("expected available events to be %v, got %v", expectedEvents, m.AvailableEvents())
+	}
+
+	err := m.Event(pay)
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	if m.CurrentState() != paid || !m.In(paid) {
+		t.Errorf("expected state to be %s, got %s", paid, m.CurrentState())
+	}
+
+	if m.Can(pay) || !m.Can(cancel) {
+		t.Errorf("expected to only be able to %s", cancel)
+	}
+
+	m.Event(cancel)
+	if !m.IsFinal() || m.Untyped().CurrentState() != "cancelled" {
+		t.Errorf("expected state to be final, got %s", m.CurrentState())
+	}
+}
+
+func TestMachineCallbacks(t *testing.T) {
+	called := []string{}
+
+	m := state52.MustNewMachine(
+		order.Initial(pending),
+		order.Events(state52.TypedEvents[orderState, orderEvent]{
+			{
+				Name: pay,
+				Transitions: state52.TypedTransitions[orderState, orderEvent]{
+					{From: []orderState{pending}, To: paid,
+						Guards: []state52.Guard{order.Guard(func(e *orderFiring) bool {
+							return e.Name == pay && e.Args()[0].(int) >= 100
+						})},
+						Callbacks: state52.TypedTransitionCallbacks[orderState, orderEvent]{
+							"success": func(m *orderMachine, e *orderFiring, t *state52.TypedTransition[orderState, orderEvent]) error {
+								called = append(called, fmt.Sprintf("success:%s", t.To))
+								return m.Raise(cancel)
+							},
+						},
+					},
+				},
+				Callbacks: state52.TypedCallbacks[orderState, orderEvent]{
+					"after": func(m *orderMachine, e *orderFiring) error {
+						called = append(called, fmt.Sprintf("after:%s", e.Name))
+						return nil
+					},
+				},
+			},
+			{
+				Name: cancel,
+				Transitions: state52.TypedTransitions[orderState, orderEvent]{
+					{From: []orderState{paid}, To: cancelled},
+				},
+			},
+		}),
+		order.StateCallbacks(paid, state52.TypedStateCallbacks[orderState, orderEvent]{
+			"on_enter": func(m *orderMachine, e *orderFiring, state orderState) error {
+				called = append(called, fmt.Sprintf("on_enter:%s", state))
+				return nil
+			},
+		}),
+		order.GlobalStateHooks(state52.TypedStateHooks[orderState, orderEvent]{
+			"on_exit": {
+				{Name: "log", Fn: func(m *orderMachine, e *orderFiring, state orderState) error {
+					called = append(called, fmt.Sprintf("on_exit:%s", state))
+					return nil
+				}},
+			},
+		}),
+	)
+
+	if m.Can(pay, 50) {
+		t.Errorf("expected pay with 50 not to be possible")
+	}
+
+	err := m.Event(pay, 100)
+	if err != nil {
+		t.Errorf("expected error message to be: nil, got %s", err.Error())
+	}
+
+	expected := []string{"on_exit:pending", "on_enter:paid", "success:paid", "after:pay", "on_exit:paid"}
+	if fmt.Sprint(called) != fmt.Sprint(expected) {
+		t.Errorf("expected callbacks to be called in order: %v, got %v", expected, called)
+	}
+
+	if m.CurrentState() != cancelled {
+		t.Errorf("expected state to be %s, got %s", cancelled, m.CurrentState())
+	}
+}
+
+func TestStringMachine(t *testing.T) {
+	var typed state52.Typed[string, string]
+
+	m := state52.MustNewMachine(
+		typed.Initial("start"),
+		typed.Events(state52.TypedEvents[string, string]{
+			{
+				Name: "first_event",
+				Transitions: state52.TypedTransitions[string, string]{
+					{From: []string{"start"}, To: "succeeded_first"},
+				},
+			},
+		}),
+		typed.With(state52.SetHistory(10)),
+	)
+
+	err := m.Event("second_event")
+	expectedError := "second_event is not registered."
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("expected error message to be: %s, got %v", expectedError, err)
+	}
+
+	m.Event("first_event")
+	if m.CurrentState() != "succeeded_first" {
+		t.Errorf("expected state to be 'succeeded_first', got %s", m.CurrentState())
+	}
+
+	if len(m.Untyped().History()) != 2 {
+		t.Errorf("expected 2 events in the history, got %v", m.Untyped().History())
+	}
+}
+
+type light int
+type lightEvent int
+
+const (
+	red light = iota
+	green
+	amber
+)
+
+const (
+	next lightEvent = iota
+)
+
+func (l light) String() string {
+	return [...]string{"red", "green", "amber"}[l]
+}
+
+func TestEnumMachine(t *testing.T) {
+	var typed state52.Typed[light, lightEvent]
+	entered := []light{}
+
+	m := state52.MustNewMachine(
+		typed.Initial(red),
+		typed.Events(state52.TypedEvents[light, lightEvent]{
+			{
+				Name: next,
+				Transitions: state52.TypedTransitions[light, lightEvent]{
+					{From: []light{red}, To: green},
+					{From: []light{green}, To: amber},
+					{From: []light{amber}, To: red},
+				},
+			},
+		}),
+		typed.GlobalStateCallbacks(state52.TypedStateCallbacks[light, lightEvent]{
+			"on_enter": func(m *state52.Machine[light, lightEvent], e *state52.Firing[lightEvent], state light) error {
+				entered = append(entered, state)
+				return nil
+			},
+		}),
+	)
+
+	m.Event(next)
+	m.Event(next)
+	if m.CurrentState() != amber || fmt.Sprint(entered) != "[green amber]" {
+		t.Errorf("expected state to be %s after entering [green amber], got %s after entering %v", amber, m.CurrentState(), entered)
+	}
+
+	if m.Untyped().CurrentState() != "amber" || !m.Untyped().Can("0") {
+		t.Errorf("expected the untyped state machine to name states with String() & events with fmt.Sprint, got %s", m.Untyped().CurrentState())
+	}
+
+	if fmt.Sprint(m.AvailableEvents()) != fmt.Sprint([]lightEvent{next}) {
+		t.Errorf("expected available events to be %v, got %v", []lightEvent{next}, m.AvailableEvents())
+	}
+}
+
+type clashingLight int
+
+func (clashingLight) String() string {
+	return "light"
+}
+
+func TestMachineNamesMustBeDistinct(t *testing.T) {
+	var typed state52.Typed[clashingLight, lightEvent]
+
+	_, err := state52.NewMachine(
+		typed.Initial(0),
+		typed.Events(state52.TypedEvents[clashingLight, lightEvent]{
+			{
+				Name: next,
+				Transitions: state52.TypedTransitions[clashingLight, lightEvent]{
+					{From: []clashingLight{0}, To: 1},
+				},
+			},
+		}),
+	)
+
+	expectedError := "Distinct state52_test.clashingLight values are both named light."
+	if err == nil || !strings.Contains(err.Error(), expectedError) {
+		t.Errorf("expected error message to contain: %s, got %v", expectedError, err)
+	}
+}